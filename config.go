@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/BurntSushi/toml"
+	"github.com/webrpc/ridlfmt/formatter"
+	"gopkg.in/yaml.v3"
+)
+
+// configFileNames are looked up in every directory from an input file up to
+// the repository root. The first one found in a directory is used.
+var configFileNames = []string{".ridlfmt.yaml", ".ridlfmt.yml", ".ridlfmt.toml"}
+
+// fileConfig is the content of a .ridlfmt.yaml or .ridlfmt.toml file. Unset
+// keys keep the value inherited from parent directories.
+type fileConfig struct {
+	SortErrors *errorSort `yaml:"sort-errors" toml:"sort-errors"`
+
+	SortImports     *bool `yaml:"sort-imports" toml:"sort-imports"`
+	SortTags        *bool `yaml:"sort-tags" toml:"sort-tags"`
+	SortAnnotations *bool `yaml:"sort-annotations" toml:"sort-annotations"`
+	SortEnumValues  *bool `yaml:"sort-enum-values" toml:"sort-enum-values"`
+
+	FieldIndent *int  `yaml:"field-indent" toml:"field-indent"`
+	TagIndent   *int  `yaml:"tag-indent" toml:"tag-indent"`
+	UseTabs     *bool `yaml:"use-tabs" toml:"use-tabs"`
+	TabWidth    *int  `yaml:"tab-width" toml:"tab-width"`
+	AlignErrors *bool `yaml:"align-errors" toml:"align-errors"`
+
+	AlignFields        *bool `yaml:"align-fields" toml:"align-fields"`
+	AlignFieldComments *bool `yaml:"align-field-comments" toml:"align-field-comments"`
+	AlignTags          *bool `yaml:"align-tags" toml:"align-tags"`
+	AlignComments      *bool `yaml:"align-comments" toml:"align-comments"`
+	MaxCommentColumn   *int  `yaml:"max-comment-column" toml:"max-comment-column"`
+	MaxWidth           *int  `yaml:"max-width" toml:"max-width"`
+
+	SeparateDecls        *bool `yaml:"separate-decls" toml:"separate-decls"`
+	SeparateTaggedFields *bool `yaml:"separate-tagged-fields" toml:"separate-tagged-fields"`
+	CompactHeader        *bool `yaml:"compact-header" toml:"compact-header"`
+	TrimBlocks           *bool `yaml:"trim-blocks" toml:"trim-blocks"`
+
+	LineEnding *string `yaml:"line-ending" toml:"line-ending"`
+}
+
+func (c *fileConfig) apply(opts *formatter.Options) {
+	if c.SortErrors != nil {
+		opts.SortErrors = c.SortErrors.sort
+		opts.ErrorSortKeys = c.SortErrors.keys
+	}
+	setBool(&opts.SortImports, c.SortImports)
+	setBool(&opts.SortTags, c.SortTags)
+	setBool(&opts.SortAnnotations, c.SortAnnotations)
+	setBool(&opts.SortEnumValues, c.SortEnumValues)
+	setInt(&opts.FieldIndent, c.FieldIndent)
+	setInt(&opts.TagIndent, c.TagIndent)
+	setBool(&opts.UseTabs, c.UseTabs)
+	setInt(&opts.TabWidth, c.TabWidth)
+	setBool(&opts.AlignErrors, c.AlignErrors)
+	setBool(&opts.AlignFields, c.AlignFields)
+	setBool(&opts.AlignFieldComments, c.AlignFieldComments)
+	setBool(&opts.AlignTags, c.AlignTags)
+	setBool(&opts.AlignComments, c.AlignComments)
+	setInt(&opts.MaxCommentColumn, c.MaxCommentColumn)
+	setInt(&opts.MaxWidth, c.MaxWidth)
+	setBool(&opts.SeparateDecls, c.SeparateDecls)
+	setBool(&opts.SeparateTaggedFields, c.SeparateTaggedFields)
+	setBool(&opts.CompactHeader, c.CompactHeader)
+	setBool(&opts.TrimBlocks, c.TrimBlocks)
+	if c.LineEnding != nil {
+		opts.LineEnding = formatter.LineEnding(*c.LineEnding)
+		if *c.LineEnding == "auto" {
+			opts.LineEnding = formatter.LineEndingAuto
+		}
+	}
+}
+
+// errorSort is the value of the sort-errors key: either a boolean, sorting by
+// code, or a comma-separated list of sort keys.
+type errorSort struct {
+	sort bool
+	keys []formatter.ErrorSortKey
+}
+
+func (s *errorSort) UnmarshalYAML(value *yaml.Node) error {
+	if err := value.Decode(&s.sort); err == nil {
+		return nil
+	}
+
+	var keys string
+	if err := value.Decode(&keys); err != nil {
+		return err
+	}
+
+	return s.parse(keys)
+}
+
+func (s *errorSort) UnmarshalTOML(value interface{}) error {
+	switch v := value.(type) {
+	case bool:
+		s.sort = v
+		return nil
+	case string:
+		return s.parse(v)
+	}
+
+	return fmt.Errorf("sort-errors must be a boolean or a list of sort keys")
+}
+
+func (s *errorSort) parse(keys string) error {
+	var err error
+	s.keys, err = formatter.ParseErrorSortKeys(keys)
+	s.sort = err == nil
+
+	return err
+}
+
+func setBool(dst *bool, v *bool) {
+	if v != nil {
+		*dst = *v
+	}
+}
+
+func setInt(dst *int, v *int) {
+	if v != nil {
+		*dst = *v
+	}
+}
+
+func loadConfigFile(fileName string) (*fileConfig, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	var c fileConfig
+	if filepath.Ext(fileName) == ".toml" {
+		md, err := toml.Decode(string(data), &c)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", fileName, err)
+		}
+
+		if undecoded := md.Undecoded(); len(undecoded) != 0 {
+			return nil, fmt.Errorf("%s: unknown option %q", fileName, undecoded[0].String())
+		}
+	} else {
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(&c); err != nil && len(bytes.TrimSpace(data)) != 0 {
+			return nil, fmt.Errorf("%s: %w", fileName, err)
+		}
+	}
+
+	for _, v := range []struct {
+		key   string
+		value *int
+	}{
+		{"field-indent", c.FieldIndent},
+		{"tag-indent", c.TagIndent},
+		{"tab-width", c.TabWidth},
+		{"max-width", c.MaxWidth},
+		{"max-comment-column", c.MaxCommentColumn},
+	} {
+		if v.value != nil && *v.value < 0 {
+			return nil, fmt.Errorf("%s: %s must not be negative", fileName, v.key)
+		}
+	}
+
+	if c.LineEnding != nil {
+		switch *c.LineEnding {
+		case "auto", "lf", "crlf":
+		default:
+			return nil, fmt.Errorf("%s: line-ending must be auto, lf or crlf", fileName)
+		}
+	}
+
+	return &c, nil
+}
+
+// configResolver computes the options of input files from the config files
+// above them, then applies the options given on the command line, which take
+// precedence.
+type configResolver struct {
+	flags func(opts *formatter.Options)
+	dirs  *dirCache[*formatter.Options]
+}
+
+func newConfigResolver(flags func(opts *formatter.Options)) *configResolver {
+	return &configResolver{
+		flags: flags,
+		dirs:  newDirCache(dirOptions),
+	}
+}
+
+// options returns the options for files in dir.
+func (r *configResolver) options(dir string) (formatter.Options, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return formatter.Options{}, err
+	}
+
+	dirOpts, err := r.dirs.get(dir)
+	if err != nil {
+		return formatter.Options{}, err
+	}
+
+	opts := *dirOpts
+	r.flags(&opts)
+
+	return opts, nil
+}
+
+// dirOptions applies the config file of dir, if any, over the options of its
+// parent directory, so deeper files override.
+func dirOptions(dir string, parent *formatter.Options) (*formatter.Options, error) {
+	opts := formatter.DefaultOptions()
+	if parent != nil {
+		opts = *parent
+	}
+
+	for _, name := range configFileNames {
+		fileName := filepath.Join(dir, name)
+		if !exists(fileName) {
+			continue
+		}
+
+		c, err := loadConfigFile(fileName)
+		if err != nil {
+			return nil, err
+		}
+
+		c.apply(&opts)
+
+		break
+	}
+
+	return &opts, nil
+}
+
+func exists(fileName string) bool {
+	_, err := os.Stat(fileName)
+	return err == nil
+}
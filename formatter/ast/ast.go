@@ -0,0 +1,203 @@
+package ast
+
+// Node is implemented by every element of a parsed RIDL file.
+type Node interface {
+	Pos() Position
+}
+
+// Decoration holds what surrounds a node in the source: the number of blank
+// lines above it, the comment lines directly above it and the comment
+// trailing it on the same line.
+type Decoration struct {
+	EmptyLines int
+	Doc        *CommentGroup
+	Comment    *Comment
+}
+
+func (d *Decoration) Decor() *Decoration { return d }
+
+// Decorated is implemented by every node that occupies its own line.
+type Decorated interface {
+	Node
+	Decor() *Decoration
+}
+
+type Comment struct {
+	Text  string
+	Start Position
+}
+
+func (c *Comment) Pos() Position { return c.Start }
+
+// CommentGroup is a run of comment lines. When it is not attached to a node
+// as its Doc it is a floating group, separated by blank lines from what
+// follows.
+type CommentGroup struct {
+	EmptyLines int
+	List       []*Comment
+}
+
+func (g *CommentGroup) Pos() Position { return g.List[0].Start }
+
+// Bad holds source lines that couldn't be parsed, exactly as they appear in
+// the input.
+type Bad struct {
+	EmptyLines int
+	Start      Position
+	Text       string
+}
+
+func (b *Bad) Pos() Position { return b.Start }
+
+// Verbatim holds the lines between `# ridlfmt:off` and `# ridlfmt:on`
+// directives, both included, exactly as they appear in the input.
+type Verbatim struct {
+	EmptyLines int
+	Start      Position
+	Text       string
+}
+
+func (v *Verbatim) Pos() Position { return v.Start }
+
+type File struct {
+	Decls []Node
+}
+
+// Definition is one of the header lines: webrpc, name or version.
+type Definition struct {
+	Decoration
+	Start Position
+	Key   string
+	Value string
+}
+
+func (d *Definition) Pos() Position { return d.Start }
+
+type Import struct {
+	Decoration
+	Start Position
+	Path  string
+	Body  []Node
+}
+
+func (i *Import) Pos() Position { return i.Start }
+
+type ImportMember struct {
+	Decoration
+	Start Position
+	Name  string
+	Value string
+}
+
+func (m *ImportMember) Pos() Position { return m.Start }
+
+type Enum struct {
+	Decoration
+	Start Position
+	Name  string
+	Type  string
+	Body  []Node
+}
+
+func (e *Enum) Pos() Position { return e.Start }
+
+type EnumValue struct {
+	Decoration
+	Start Position
+	Name  string
+	Value string
+}
+
+func (v *EnumValue) Pos() Position { return v.Start }
+
+type Struct struct {
+	Decoration
+	Start Position
+	Name  string
+	Body  []Node
+}
+
+func (s *Struct) Pos() Position { return s.Start }
+
+type Field struct {
+	Decoration
+	Start Position
+	Name  string
+	Type  string
+	Tags  []Node
+}
+
+func (f *Field) Pos() Position { return f.Start }
+
+type Tag struct {
+	Decoration
+	Start    Position
+	Key      string
+	Value    string
+	HasValue bool
+}
+
+func (t *Tag) Pos() Position { return t.Start }
+
+// AnnotationLine is a single line of annotations, e.g. `@auth:ApiKey @internal`.
+type AnnotationLine struct {
+	Decoration
+	Start       Position
+	Annotations []*Annotation
+}
+
+func (a *AnnotationLine) Pos() Position { return a.Start }
+
+type Annotation struct {
+	Start    Position
+	Name     string
+	Value    string
+	HasValue bool
+}
+
+func (a *Annotation) Pos() Position { return a.Start }
+
+type Error struct {
+	Decoration
+	Start       Position
+	Code        int
+	Name        string
+	Description string
+	HTTPCode    int
+	HasHTTPCode bool
+}
+
+func (e *Error) Pos() Position { return e.Start }
+
+type Service struct {
+	Decoration
+	Start Position
+	Name  string
+	Body  []Node
+}
+
+func (s *Service) Pos() Position { return s.Start }
+
+type Method struct {
+	Decoration
+	Start        Position
+	Name         string
+	StreamInput  bool
+	Inputs       []*Argument
+	HasOutputs   bool
+	StreamOutput bool
+	Outputs      []*Argument
+}
+
+func (m *Method) Pos() Position { return m.Start }
+
+// Argument is a method input or output. It only has comments when the
+// argument list is wrapped over several lines.
+type Argument struct {
+	Decoration
+	Start Position
+	Name  string
+	Type  string
+}
+
+func (a *Argument) Pos() Position { return a.Start }
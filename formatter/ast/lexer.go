@@ -0,0 +1,150 @@
+package ast
+
+import (
+	"strings"
+)
+
+const specialChars = "=:(),<>[]@#\"\n"
+
+type lexer struct {
+	src         string
+	offset      int
+	line        int
+	column      int
+	atLineStart bool
+}
+
+func newLexer(src string) *lexer {
+	return &lexer{
+		src:         src,
+		line:        1,
+		column:      1,
+		atLineStart: true,
+	}
+}
+
+// Lex splits src into tokens. Line breaks are kept as TokenNewline and the
+// result always ends with TokenEOF.
+func Lex(src string) []Token {
+	l := newLexer(src)
+
+	var tokens []Token
+	for {
+		t := l.next()
+		tokens = append(tokens, t)
+		if t.Kind == TokenEOF {
+			return tokens
+		}
+	}
+}
+
+func (l *lexer) next() Token {
+	l.skipSpaces()
+
+	pos := l.pos()
+	if l.offset >= len(l.src) {
+		return Token{Kind: TokenEOF, Pos: pos}
+	}
+
+	lineStart := l.atLineStart
+	l.atLineStart = false
+
+	c := l.src[l.offset]
+	switch {
+	case c == '\n':
+		l.advance(1)
+		l.atLineStart = true
+		return Token{Kind: TokenNewline, Text: "\n", Pos: pos}
+	case c == '#':
+		end := strings.IndexByte(l.src[l.offset:], '\n')
+		if end == -1 {
+			end = len(l.src) - l.offset
+		}
+		text := strings.TrimRight(l.src[l.offset:l.offset+end], " \t\r")
+		l.advance(len(text))
+		return Token{Kind: TokenComment, Text: text, Pos: pos}
+	case c == '"':
+		return l.lexString(pos)
+	case c == '-' && lineStart:
+		l.advance(1)
+		return Token{Kind: TokenDash, Text: "-", Pos: pos}
+	case c == '+' && lineStart:
+		l.advance(1)
+		return Token{Kind: TokenPlus, Text: "+", Pos: pos}
+	case c == '=' && strings.HasPrefix(l.src[l.offset:], "=>"):
+		l.advance(2)
+		return Token{Kind: TokenArrow, Text: "=>", Pos: pos}
+	}
+
+	if kind, ok := punctuation[c]; ok {
+		l.advance(1)
+		return Token{Kind: kind, Text: string(c), Pos: pos}
+	}
+
+	start := l.offset
+	for l.offset < len(l.src) && !isSpace(l.src[l.offset]) && !strings.ContainsRune(specialChars, rune(l.src[l.offset])) {
+		l.advance(1)
+	}
+
+	return Token{Kind: TokenWord, Text: l.src[start:l.offset], Pos: pos}
+}
+
+var punctuation = map[byte]TokenKind{
+	'@': TokenAt,
+	'=': TokenAssign,
+	':': TokenColon,
+	',': TokenComma,
+	'(': TokenLParen,
+	')': TokenRParen,
+	'<': TokenLAngle,
+	'>': TokenRAngle,
+	'[': TokenLBrack,
+	']': TokenRBrack,
+}
+
+func (l *lexer) lexString(pos Position) Token {
+	start := l.offset
+	l.advance(1)
+
+	for l.offset < len(l.src) {
+		switch l.src[l.offset] {
+		case '\\':
+			l.advance(2)
+			continue
+		case '"':
+			l.advance(1)
+			return Token{Kind: TokenString, Text: l.src[start:l.offset], Pos: pos}
+		case '\n':
+			return Token{Kind: TokenIllegal, Text: l.src[start:l.offset], Pos: pos}
+		}
+		l.advance(1)
+	}
+
+	return Token{Kind: TokenIllegal, Text: l.src[start:l.offset], Pos: pos}
+}
+
+func (l *lexer) skipSpaces() {
+	for l.offset < len(l.src) && isSpace(l.src[l.offset]) {
+		l.advance(1)
+	}
+}
+
+func (l *lexer) advance(n int) {
+	for i := 0; i < n && l.offset < len(l.src); i++ {
+		if l.src[l.offset] == '\n' {
+			l.line++
+			l.column = 1
+		} else {
+			l.column++
+		}
+		l.offset++
+	}
+}
+
+func (l *lexer) pos() Position {
+	return Position{Offset: l.offset, Line: l.line, Column: l.column}
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\v' || c == '\f'
+}
@@ -0,0 +1,910 @@
+package ast
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// SyntaxError is returned by Parse for input that isn't valid RIDL.
+type SyntaxError struct {
+	Pos Position
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Msg)
+}
+
+// ErrorList is returned by Parse when one or more lines couldn't be parsed.
+type ErrorList []*SyntaxError
+
+func (l ErrorList) Error() string {
+	msgs := make([]string, len(l))
+	for i, e := range l {
+		msgs[i] = e.Error()
+	}
+
+	return strings.Join(msgs, "\n")
+}
+
+type line struct {
+	start   Position
+	end     int
+	tokens  []Token
+	comment *Token
+}
+
+// offset returns the offset of the first byte of the line, including its
+// indentation.
+func (l line) offset() int {
+	return l.start.Offset - (l.start.Column - 1)
+}
+
+// isDirective reports whether the line is a `# ridlfmt:<name>` comment.
+func (l line) isDirective(name string) bool {
+	if len(l.tokens) != 0 || l.comment == nil {
+		return false
+	}
+
+	return strings.TrimSpace(strings.TrimLeft(l.comment.Text, "#")) == "ridlfmt:"+name
+}
+
+func (l line) isBlank() bool {
+	return len(l.tokens) == 0 && l.comment == nil
+}
+
+func (l line) isDecl() bool {
+	if len(l.tokens) == 0 || l.tokens[0].Kind != TokenWord {
+		return false
+	}
+
+	switch l.tokens[0].Text {
+	case "webrpc", "name", "version", "import", "enum", "struct", "service", "error":
+		return true
+	}
+
+	return false
+}
+
+type parser struct {
+	src        string
+	file       *File
+	block      Node
+	field      *Field
+	doc        *CommentGroup
+	emptyLines int
+	errors     ErrorList
+}
+
+// Parse builds the syntax tree of a RIDL document. Lines that can't be parsed
+// are reported in the returned ErrorList and kept in the tree as Bad nodes
+// spanning up to the next top-level declaration, so the returned file is
+// always usable.
+func Parse(src string) (*File, error) {
+	p := parser{
+		src:  src,
+		file: &File{},
+	}
+
+	lines := splitLines(Lex(src))
+	for i := 0; i < len(lines); i++ {
+		if lines[i].isDirective("off") {
+			i = p.skipVerbatim(lines, i)
+			continue
+		}
+
+		l, last := p.joinLines(lines, i)
+		if err := p.parseLine(l); err != nil {
+			p.errors = append(p.errors, err.(*SyntaxError))
+			i = p.skipBad(lines, i)
+			continue
+		}
+
+		i = last
+	}
+
+	if p.doc != nil {
+		p.addFloating(p.doc)
+	}
+
+	if len(p.errors) != 0 {
+		return p.file, p.errors
+	}
+
+	return p.file, nil
+}
+
+// skipVerbatim stores lines from the `# ridlfmt:off` directive at lines[i]
+// through the matching `# ridlfmt:on` (or the end of the input) as a Verbatim
+// node and returns the index of the last line it consumed.
+func (p *parser) skipVerbatim(lines []line, i int) int {
+	if p.doc != nil {
+		p.addFloating(p.doc)
+		p.doc = nil
+	}
+
+	last := i + 1
+	for last < len(lines) && !lines[last].isDirective("on") {
+		last++
+	}
+
+	trailing := 0
+	if last == len(lines) {
+		last--
+		for last > i && lines[last].isBlank() {
+			last--
+			trailing++
+		}
+	}
+
+	v := &Verbatim{
+		EmptyLines: p.emptyLines,
+		Start:      lines[i].start,
+		Text:       p.src[lines[i].offset():lines[last].end],
+	}
+	p.emptyLines = trailing
+
+	// Inside the tags of a field, the tags after the region still belong to
+	// it.
+	if p.field != nil {
+		p.field.Tags = append(p.field.Tags, v)
+		return last + trailing
+	}
+
+	p.addBodyItem(v)
+
+	return last + trailing
+}
+
+// skipBad stores lines from lines[i] up to the next top-level declaration as
+// a Bad node and returns the index of the last line it consumed.
+func (p *parser) skipBad(lines []line, i int) int {
+	if p.doc != nil {
+		p.addFloating(p.doc)
+		p.doc = nil
+	}
+
+	// Indented declaration keywords inside unclosed parentheses are wrapped
+	// method arguments, not declarations.
+	open := depth(lines[i].tokens)
+	next := i + 1
+	for next < len(lines) && !(lines[next].isDecl() && (open <= 0 || lines[next].start.Column == 1)) {
+		open += depth(lines[next].tokens)
+		next++
+	}
+
+	last := next - 1
+	for last > i && lines[last].isBlank() {
+		last--
+	}
+
+	p.file.Decls = append(p.file.Decls, &Bad{
+		EmptyLines: p.emptyLines,
+		Start:      lines[i].start,
+		Text:       p.src[lines[i].offset():lines[last].end],
+	})
+
+	p.block = nil
+	p.field = nil
+	p.emptyLines = next - 1 - last
+
+	return next - 1
+}
+
+func splitLines(tokens []Token) []line {
+	var lines []line
+	var cur line
+	var started bool
+
+	for _, t := range tokens {
+		if !started {
+			cur.start = t.Pos
+			started = true
+		}
+
+		switch t.Kind {
+		case TokenNewline, TokenEOF:
+			cur.end = t.Pos.Offset
+			if t.Kind == TokenNewline || len(cur.tokens) != 0 || cur.comment != nil {
+				lines = append(lines, cur)
+			}
+			cur = line{}
+			started = false
+		case TokenComment:
+			c := t
+			cur.comment = &c
+		default:
+			cur.tokens = append(cur.tokens, t)
+		}
+	}
+
+	return lines
+}
+
+// joinLines returns the method at lines[i] merged with the lines that follow
+// it while it has unclosed parentheses, ends with `=>` or `=> stream`, or has
+// its inputs and the next line starts with `=>`, so a method wrapped over
+// several lines is parsed as one. Comments of all but the last line are kept as TokenComment
+// in the merged tokens. It also returns the index of the last line merged.
+func (p *parser) joinLines(lines []line, i int) (line, int) {
+	l := lines[i]
+
+	last := i
+	if _, ok := p.block.(*Service); !ok || len(l.tokens) == 0 || l.tokens[0].Kind != TokenDash {
+		return l, last
+	}
+
+	for last+1 < len(lines) {
+		next := lines[last+1]
+		continues := len(next.tokens) != 0 && next.tokens[0].Kind == TokenArrow && index(l.tokens, TokenLParen) != -1
+		if depth(l.tokens) <= 0 && !endsWithArrow(l.tokens) && !continues {
+			break
+		}
+
+		l.tokens = l.tokens[:len(l.tokens):len(l.tokens)]
+		if l.comment != nil {
+			l.tokens = append(l.tokens, *l.comment)
+		}
+
+		l.tokens = append(l.tokens, next.tokens...)
+		l.comment = next.comment
+		l.end = next.end
+		last++
+	}
+
+	return l, last
+}
+
+// endsWithArrow reports whether tokens end with `=>` or `=> stream`, so the
+// output list is on a following line.
+func endsWithArrow(tokens []Token) bool {
+	n := len(tokens)
+	if n > 1 && isWord(tokens[n-1], "stream") {
+		n--
+	}
+
+	return n > 0 && tokens[n-1].Kind == TokenArrow
+}
+
+// depth returns the number of parentheses left open by tokens.
+func depth(tokens []Token) int {
+	var n int
+	for _, t := range tokens {
+		switch t.Kind {
+		case TokenLParen:
+			n++
+		case TokenRParen:
+			n--
+		}
+	}
+
+	return n
+}
+
+func (p *parser) parseLine(l line) error {
+	for _, t := range l.tokens {
+		if t.Kind == TokenIllegal {
+			return p.errorf(t.Pos, "unterminated string %s", t.Text)
+		}
+	}
+
+	if len(l.tokens) == 0 {
+		if l.comment == nil {
+			if p.doc != nil {
+				p.addFloating(p.doc)
+				p.doc = nil
+			}
+			p.emptyLines++
+
+			return nil
+		}
+
+		if p.doc == nil {
+			p.doc = &CommentGroup{EmptyLines: p.emptyLines}
+			p.emptyLines = 0
+		}
+		p.doc.List = append(p.doc.List, &Comment{Text: l.comment.Text, Start: l.comment.Pos})
+
+		return nil
+	}
+
+	n, err := p.parseNode(l.tokens)
+	if err != nil {
+		return err
+	}
+
+	d := n.Decor()
+	d.EmptyLines = p.emptyLines
+	if p.doc != nil {
+		d.EmptyLines = p.doc.EmptyLines
+		p.doc.EmptyLines = 0
+		d.Doc = p.doc
+	}
+	if l.comment != nil {
+		d.Comment = &Comment{Text: l.comment.Text, Start: l.comment.Pos}
+	}
+
+	p.doc = nil
+	p.emptyLines = 0
+
+	return nil
+}
+
+func (p *parser) parseNode(tokens []Token) (Decorated, error) {
+	first := tokens[0]
+
+	switch first.Kind {
+	case TokenWord:
+		n, err := p.parseDecl(tokens)
+		if err != nil {
+			return nil, err
+		}
+
+		p.file.Decls = append(p.file.Decls, n)
+		p.field = nil
+
+		return n, nil
+	case TokenDash:
+		return p.parseBlockItem(tokens)
+	case TokenPlus:
+		if p.field == nil {
+			return nil, p.errorf(first.Pos, "tag outside of a struct field")
+		}
+
+		t := p.parseTag(tokens)
+		p.field.Tags = append(p.field.Tags, t)
+
+		return t, nil
+	case TokenAt:
+		a, err := p.parseAnnotationLine(tokens)
+		if err != nil {
+			return nil, err
+		}
+
+		p.addBodyItem(a)
+
+		return a, nil
+	}
+
+	return nil, p.errorf(first.Pos, "unexpected %s", first.Kind)
+}
+
+func (p *parser) parseDecl(tokens []Token) (Decorated, error) {
+	first := tokens[0]
+	p.block = nil
+
+	switch first.Text {
+	case "webrpc", "name", "version":
+		if len(tokens) < 2 || tokens[1].Kind != TokenAssign {
+			return nil, p.errorf(first.Pos, "expected '=' after %s", first.Text)
+		}
+
+		return &Definition{Start: first.Pos, Key: first.Text, Value: join(tokens[2:])}, nil
+	case "import":
+		i := &Import{Start: first.Pos, Path: join(tokens[1:])}
+		p.block = i
+
+		return i, nil
+	case "enum":
+		if len(tokens) < 2 || tokens[1].Kind != TokenWord {
+			return nil, p.errorf(first.Pos, "missing enum name")
+		}
+
+		e := &Enum{Start: first.Pos, Name: tokens[1].Text}
+		if len(tokens) > 2 {
+			if tokens[2].Kind != TokenColon {
+				return nil, p.errorf(tokens[2].Pos, "expected ':' after enum name")
+			}
+
+			if len(tokens) == 3 {
+				return nil, p.errorf(tokens[2].Pos, "missing enum type")
+			}
+
+			e.Type = join(tokens[3:])
+		}
+
+		p.block = e
+
+		return e, nil
+	case "struct":
+		name, err := p.parseName(tokens)
+		if err != nil {
+			return nil, err
+		}
+
+		s := &Struct{Start: first.Pos, Name: name}
+		p.block = s
+
+		return s, nil
+	case "service":
+		name, err := p.parseName(tokens)
+		if err != nil {
+			return nil, err
+		}
+
+		s := &Service{Start: first.Pos, Name: name}
+		p.block = s
+
+		return s, nil
+	case "error":
+		return p.parseError(tokens)
+	}
+
+	return nil, p.errorf(first.Pos, "unknown declaration %q", first.Text)
+}
+
+// parseName returns the name of a struct or service declaration.
+func (p *parser) parseName(tokens []Token) (string, error) {
+	if len(tokens) < 2 || tokens[1].Kind != TokenWord {
+		return "", p.errorf(tokens[0].Pos, "missing %s name", tokens[0].Text)
+	}
+
+	if len(tokens) > 2 {
+		return "", p.errorf(tokens[2].Pos, "unexpected %q after %s name", tokens[2].Text, tokens[0].Text)
+	}
+
+	return tokens[1].Text, nil
+}
+
+// parseIdent returns the name of a body item. It may only hold words, which
+// are joined when split by spaces.
+func (p *parser) parseIdent(dash Token, tokens []Token, what string) (string, error) {
+	if len(tokens) == 0 {
+		return "", p.errorf(dash.Pos, "missing %s name", what)
+	}
+
+	for _, t := range tokens {
+		if t.Kind != TokenWord {
+			return "", p.errorf(t.Pos, "unexpected %q in %s name", t.Text, what)
+		}
+	}
+
+	return join(tokens), nil
+}
+
+func (p *parser) parseError(tokens []Token) (*Error, error) {
+	start := tokens[0].Pos
+	if len(tokens) != 4 && len(tokens) != 6 {
+		return nil, p.errorf(start, "wrong error format, expected: error <code> <name> \"<description>\" HTTP <status>")
+	}
+
+	code, err := strconv.Atoi(tokens[1].Text)
+	if err != nil || tokens[1].Kind != TokenWord {
+		return nil, p.errorf(tokens[1].Pos, "invalid error code %q", tokens[1].Text)
+	}
+
+	if tokens[2].Kind != TokenWord {
+		return nil, p.errorf(tokens[2].Pos, "invalid error name %q", tokens[2].Text)
+	}
+
+	if tokens[3].Kind != TokenString {
+		return nil, p.errorf(tokens[3].Pos, "error description must be a quoted string")
+	}
+
+	e := &Error{
+		Start:       start,
+		Code:        code,
+		Name:        tokens[2].Text,
+		Description: tokens[3].Text[1 : len(tokens[3].Text)-1],
+	}
+
+	if len(tokens) == 6 {
+		if !strings.EqualFold(tokens[4].Text, "HTTP") {
+			return nil, p.errorf(tokens[4].Pos, "expected HTTP, found %q", tokens[4].Text)
+		}
+
+		e.HTTPCode, err = strconv.Atoi(tokens[5].Text)
+		if err != nil {
+			return nil, p.errorf(tokens[5].Pos, "invalid HTTP status %q", tokens[5].Text)
+		}
+		e.HasHTTPCode = true
+	}
+
+	return e, nil
+}
+
+func (p *parser) parseBlockItem(tokens []Token) (Decorated, error) {
+	var n Decorated
+	var err error
+
+	switch b := p.block.(type) {
+	case *Import:
+		n = p.parseImportMember(tokens)
+	case *Enum:
+		n, err = p.parseEnumValue(tokens)
+	case *Struct:
+		var f *Field
+		f, err = p.parseField(tokens)
+		if err == nil {
+			b.Body = append(b.Body, f)
+			p.field = f
+			return f, nil
+		}
+	case *Service:
+		n, err = p.parseMethod(tokens)
+	default:
+		return nil, p.errorf(tokens[0].Pos, "unexpected '-' outside of a declaration")
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	p.addBodyItem(n)
+
+	return n, nil
+}
+
+func (p *parser) parseImportMember(tokens []Token) *ImportMember {
+	m := &ImportMember{Start: tokens[0].Pos}
+	if len(tokens) == 1 {
+		return m
+	}
+
+	s := reduceSpaces(p.raw(tokens[1:]))
+	if name, value, found := strings.Cut(s, ":"); found {
+		m.Name = strings.TrimSpace(name)
+		m.Value = strings.TrimSpace(value)
+	} else {
+		m.Name = s
+	}
+
+	return m
+}
+
+func (p *parser) parseEnumValue(tokens []Token) (*EnumValue, error) {
+	v := &EnumValue{Start: tokens[0].Pos}
+
+	end := len(tokens)
+	if a := index(tokens, TokenAssign); a != -1 {
+		end = a
+		v.Value = join(tokens[a+1:])
+	}
+
+	var err error
+	v.Name, err = p.parseIdent(tokens[0], tokens[1:end], "enum value")
+	if err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+func (p *parser) parseField(tokens []Token) (*Field, error) {
+	c := index(tokens, TokenColon)
+	if c == -1 {
+		return nil, p.errorf(tokens[0].Pos, "expected ':' in struct field")
+	}
+
+	if c == 1 || c == len(tokens)-1 {
+		return nil, p.errorf(tokens[0].Pos, "struct field needs a name and a type")
+	}
+
+	name, err := p.parseIdent(tokens[0], tokens[1:c], "struct field")
+	if err != nil {
+		return nil, err
+	}
+
+	return &Field{Start: tokens[0].Pos, Name: name, Type: join(tokens[c+1:])}, nil
+}
+
+func (p *parser) parseTag(tokens []Token) *Tag {
+	t := &Tag{Start: tokens[0].Pos}
+	if len(tokens) == 1 {
+		return t
+	}
+
+	a := index(tokens, TokenAssign)
+	if a == -1 {
+		t.Key = reduceSpaces(p.raw(tokens[1:]))
+		return t
+	}
+
+	if a > 1 {
+		t.Key = reduceSpaces(p.raw(tokens[1:a]))
+	}
+	if a+1 < len(tokens) {
+		t.Value = p.raw(tokens[a+1:])
+	}
+	t.HasValue = true
+
+	return t
+}
+
+func (p *parser) parseAnnotationLine(tokens []Token) (*AnnotationLine, error) {
+	l := &AnnotationLine{Start: tokens[0].Pos}
+
+	var cur []Token
+	flush := func() error {
+		if len(cur) == 0 {
+			return nil
+		}
+
+		a := &Annotation{Start: cur[0].Pos}
+		c := index(cur, TokenColon)
+		if c == -1 {
+			a.Name = join(cur[1:])
+		} else {
+			a.Name = join(cur[1:c])
+			a.Value = join(cur[c+1:])
+			a.HasValue = true
+		}
+
+		if a.Name == "" {
+			return p.errorf(a.Start, "empty annotation")
+		}
+
+		l.Annotations = append(l.Annotations, a)
+		cur = nil
+
+		return nil
+	}
+
+	for _, t := range tokens {
+		if t.Kind == TokenAt {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+		cur = append(cur, t)
+	}
+
+	if err := flush(); err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+func (p *parser) parseMethod(tokens []Token) (*Method, error) {
+	m := &Method{Start: tokens[0].Pos}
+
+	i := 1
+	if i+1 < len(tokens) && isWord(tokens[i], "stream") && tokens[i+1].Kind == TokenWord {
+		m.StreamInput = true
+		i++
+	}
+
+	lp := index(tokens, TokenLParen)
+	if lp == -1 {
+		return nil, p.errorf(tokens[0].Pos, "missing '(' in method")
+	}
+
+	var err error
+	m.Name, err = p.parseIdent(tokens[0], tokens[i:lp], "method")
+	if err != nil {
+		return nil, err
+	}
+
+	l := argumentList{p: p}
+	i, err = l.parse(tokens, lp)
+	if err != nil {
+		return nil, err
+	}
+	m.Inputs = l.args
+
+	if i = l.skipComments(tokens, i); i == len(tokens) {
+		return m, l.checkDoc()
+	}
+
+	if tokens[i].Kind != TokenArrow {
+		return nil, p.errorf(tokens[i].Pos, "unexpected %q after method arguments", tokens[i].Text)
+	}
+	m.HasOutputs = true
+	i = l.skipComments(tokens, i+1)
+
+	if i < len(tokens) && isWord(tokens[i], "stream") {
+		m.StreamOutput = true
+		i = l.skipComments(tokens, i+1)
+	}
+
+	if i == len(tokens) || tokens[i].Kind != TokenLParen {
+		return nil, p.errorf(tokens[i-1].Pos, "missing '(' after '=>'")
+	}
+
+	l.args = nil
+	i, err = l.parse(tokens, i)
+	if err != nil {
+		return nil, err
+	}
+	m.Outputs = l.args
+
+	if i = l.skipComments(tokens, i); i != len(tokens) {
+		return nil, p.errorf(tokens[i].Pos, "unexpected %q after method", tokens[i].Text)
+	}
+
+	return m, l.checkDoc()
+}
+
+// argumentList parses the parenthesized argument lists of a method. Comments
+// found in wrapped lists become the inline comment of the argument ending on
+// the same line, or the doc of the next argument.
+type argumentList struct {
+	p       *parser
+	args    []*Argument
+	last    *Argument
+	endLine int
+	doc     *CommentGroup
+}
+
+// parse parses the argument list starting at tokens[lp] and returns the
+// index just past the closing parenthesis.
+func (l *argumentList) parse(tokens []Token, lp int) (int, error) {
+	var part, after []Token
+	var comment *Comment
+	var depth int
+
+	addArg := func(end Token) error {
+		if len(part) == 0 {
+			if end.Kind == TokenRParen {
+				return nil
+			}
+
+			return l.p.errorf(end.Pos, "empty argument")
+		}
+
+		c := index(part, TokenColon)
+		if c == -1 {
+			return l.p.errorf(part[0].Pos, "missing ':' in arguments for method")
+		}
+
+		a := &Argument{Start: part[0].Pos, Name: join(part[:c]), Type: join(part[c+1:])}
+		if a.Name == "" || a.Type == "" {
+			return l.p.errorf(part[0].Pos, "argument needs a name and a type")
+		}
+
+		a.Doc = l.doc
+		a.Comment = comment
+		l.args = append(l.args, a)
+		l.last = a
+		l.endLine = part[len(part)-1].Pos.Line
+		l.doc = nil
+		part = nil
+		comment = nil
+
+		for _, t := range after {
+			l.addComment(t)
+		}
+		after = nil
+
+		return nil
+	}
+
+	for i := lp + 1; i < len(tokens); i++ {
+		t := tokens[i]
+
+		switch t.Kind {
+		case TokenComment:
+			switch {
+			case len(part) == 0:
+				l.addComment(t)
+			case part[len(part)-1].Pos.Line == t.Pos.Line:
+				comment = &Comment{Text: t.Text, Start: t.Pos}
+			default:
+				after = append(after, t)
+			}
+
+			continue
+		case TokenLAngle:
+			depth++
+		case TokenRAngle:
+			depth--
+		case TokenComma:
+			if depth == 0 {
+				if err := addArg(t); err != nil {
+					return 0, err
+				}
+
+				continue
+			}
+		case TokenRParen:
+			if err := addArg(t); err != nil {
+				return 0, err
+			}
+
+			return i + 1, nil
+		}
+
+		part = append(part, t)
+	}
+
+	return 0, l.p.errorf(tokens[lp].Pos, "missing ')'")
+}
+
+// skipComments consumes the comments starting at tokens[i] and returns the
+// index of the first other token.
+func (l *argumentList) skipComments(tokens []Token, i int) int {
+	for i < len(tokens) && tokens[i].Kind == TokenComment {
+		l.addComment(tokens[i])
+		i++
+	}
+
+	return i
+}
+
+func (l *argumentList) addComment(t Token) {
+	c := &Comment{Text: t.Text, Start: t.Pos}
+	if l.last != nil && l.last.Comment == nil && l.endLine == t.Pos.Line {
+		l.last.Comment = c
+		return
+	}
+
+	if l.doc == nil {
+		l.doc = &CommentGroup{}
+	}
+	l.doc.List = append(l.doc.List, c)
+}
+
+// checkDoc reports comments that aren't followed by an argument.
+func (l *argumentList) checkDoc() error {
+	if l.doc != nil {
+		return l.p.errorf(l.doc.Pos(), "comment must be followed by an argument")
+	}
+
+	return nil
+}
+
+func (p *parser) addBodyItem(n Node) {
+	p.field = nil
+
+	switch b := p.block.(type) {
+	case *Import:
+		b.Body = append(b.Body, n)
+	case *Enum:
+		b.Body = append(b.Body, n)
+	case *Struct:
+		b.Body = append(b.Body, n)
+	case *Service:
+		b.Body = append(b.Body, n)
+	default:
+		p.file.Decls = append(p.file.Decls, n)
+	}
+}
+
+func (p *parser) addFloating(g *CommentGroup) {
+	if p.field != nil {
+		p.field.Tags = append(p.field.Tags, g)
+		return
+	}
+
+	p.addBodyItem(g)
+}
+
+func (p *parser) raw(tokens []Token) string {
+	return strings.TrimSpace(p.src[tokens[0].Pos.Offset:tokens[len(tokens)-1].End()])
+}
+
+func (p *parser) errorf(pos Position, format string, args ...interface{}) error {
+	return &SyntaxError{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func join(tokens []Token) string {
+	var sb strings.Builder
+	for _, t := range tokens {
+		sb.WriteString(t.Text)
+	}
+
+	return sb.String()
+}
+
+func index(tokens []Token, kind TokenKind) int {
+	for i, t := range tokens {
+		if t.Kind == kind {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func isWord(t Token, s string) bool {
+	return t.Kind == TokenWord && t.Text == s
+}
+
+var spacesPattern = regexp.MustCompile(`\s+`)
+
+func reduceSpaces(s string) string {
+	return spacesPattern.ReplaceAllString(s, " ")
+}
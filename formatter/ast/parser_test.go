@@ -0,0 +1,193 @@
+package ast
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	src := `webrpc = v1
+
+# users
+struct User
+  - id: uint64 # primary key
+    + go.tag.db = "id#pk"
+  - meta: map < string , any >
+
+error 1 NotFound "not # found" HTTP 404
+
+service API
+  @auth:Key
+  - stream Get(a: uint64, m: map<string,map<string,int>>) => stream (u: User)
+`
+
+	file, err := Parse(src)
+	require.NoError(t, err)
+	require.Len(t, file.Decls, 4)
+
+	def := file.Decls[0].(*Definition)
+	require.Equal(t, "webrpc", def.Key)
+	require.Equal(t, "v1", def.Value)
+
+	s := file.Decls[1].(*Struct)
+	require.Equal(t, "User", s.Name)
+	require.Equal(t, 1, s.EmptyLines)
+	require.Equal(t, "# users", s.Doc.List[0].Text)
+	require.Len(t, s.Body, 2)
+
+	id := s.Body[0].(*Field)
+	require.Equal(t, "# primary key", id.Comment.Text)
+	require.Equal(t, Position{Offset: 35, Line: 5, Column: 3}, id.Pos())
+
+	tag := id.Tags[0].(*Tag)
+	require.Equal(t, "go.tag.db", tag.Key)
+	require.Equal(t, `"id#pk"`, tag.Value)
+	require.Equal(t, "map<string,any>", s.Body[1].(*Field).Type)
+
+	e := file.Decls[2].(*Error)
+	require.Equal(t, "not # found", e.Description)
+	require.Equal(t, 404, e.HTTPCode)
+
+	svc := file.Decls[3].(*Service)
+	require.IsType(t, &AnnotationLine{}, svc.Body[0])
+
+	m := svc.Body[1].(*Method)
+	require.Equal(t, "Get", m.Name)
+	require.True(t, m.StreamInput)
+	require.True(t, m.StreamOutput)
+	require.Len(t, m.Inputs, 2)
+	require.Equal(t, "map<string,map<string,int>>", m.Inputs[1].Type)
+	require.Equal(t, "User", m.Outputs[0].Type)
+}
+
+func TestParseError(t *testing.T) {
+	_, err := Parse("struct User\n  - id uint64\n")
+	require.EqualError(t, err, "2:3: expected ':' in struct field")
+}
+
+func TestParseWrappedMethod(t *testing.T) {
+	src := `service API
+  - Get(
+      a: uint64,
+      b: string
+    ) => (
+      u: User
+    ) # get
+  - Ping()
+`
+
+	file, err := Parse(src)
+	require.NoError(t, err)
+
+	svc := file.Decls[0].(*Service)
+	require.Len(t, svc.Body, 2)
+
+	m := svc.Body[0].(*Method)
+	require.Len(t, m.Inputs, 2)
+	require.Equal(t, "u", m.Outputs[0].Name)
+	require.Equal(t, "# get", m.Comment.Text)
+
+}
+
+func TestParseMultiLineMethod(t *testing.T) {
+	src := `service API
+  - Get( # request
+      a: uint64, # id
+      # filter
+      b: string,
+    )
+    => stream ( # response
+      u: User
+    )
+`
+
+	file, err := Parse(src)
+	require.NoError(t, err)
+
+	m := file.Decls[0].(*Service).Body[0].(*Method)
+	require.Len(t, m.Inputs, 2)
+	require.Equal(t, "# request", m.Inputs[0].Doc.List[0].Text)
+	require.Equal(t, "# id", m.Inputs[0].Comment.Text)
+	require.Equal(t, "# filter", m.Inputs[1].Doc.List[0].Text)
+	require.True(t, m.StreamOutput)
+	require.Equal(t, "# response", m.Outputs[0].Doc.List[0].Text)
+
+	_, err = Parse("service API\n  - Get(\n      a: uint64\n      # dangling\n    )\n")
+	require.EqualError(t, err, "4:7: comment must be followed by an argument")
+}
+
+func TestParseBadWrappedMethod(t *testing.T) {
+	src := `service API
+  - Get(
+      name: string,
+      error string
+    )
+
+struct User
+  - id: uint64
+`
+
+	file, err := Parse(src)
+	require.EqualError(t, err, "4:7: missing ':' in arguments for method")
+	require.Len(t, file.Decls, 3)
+
+	bad := file.Decls[1].(*Bad)
+	require.Equal(t, "  - Get(\n      name: string,\n      error string\n    )", bad.Text)
+	require.Equal(t, "User", file.Decls[2].(*Struct).Name)
+}
+
+func TestParseBadNames(t *testing.T) {
+	tests := []struct {
+		src      string
+		expected string
+	}{
+		{"service API\n  - Get => (x: int)\n", "2:9: unexpected \"=>\" in method name"},
+		{"service API\n  - Del = 2 (a: int)\n", "2:9: unexpected \"=\" in method name"},
+		{"service API\n  - (a: int)\n", "2:3: missing method name"},
+		{"struct User\n  - id = 1: uint64\n", "2:8: unexpected \"=\" in struct field name"},
+		{"enum Kind: uint32\n  - A: B = 1\n", "2:6: unexpected \":\" in enum value name"},
+	}
+
+	for _, tt := range tests {
+		_, err := Parse(tt.src)
+		require.EqualError(t, err, tt.expected, tt.src)
+	}
+
+	file, err := Parse("service API\n  - stream Re cv(req: string)\n")
+	require.NoError(t, err)
+	require.Equal(t, "Recv", file.Decls[0].(*Service).Body[0].(*Method).Name)
+}
+
+func TestDeclReader(t *testing.T) {
+	src := "webrpc = v1\r\nname = example\r\n\r\nstruct User\r\n  - id: uint64\r\n  # floating\r\n\r\n# doc\r\nservice API\r\n  - Get(\r\n      error: string\r\n    )\r\n" +
+		"# ridlfmt:off\r\nerror 1   A   \"a\"\r\n# ridlfmt:on\r\nerror 2 B \"b\"\r\n\r\nerror 3 C \"c\"\r\nenum Kind: uint32"
+
+	d := NewDeclReader(bufio.NewReader(strings.NewReader(src)))
+
+	var chunks []string
+	var lines []int
+	for {
+		chunk, start, err := d.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+
+		chunks = append(chunks, chunk)
+		lines = append(lines, start.Line)
+	}
+
+	require.True(t, d.CRLF())
+	require.Equal(t, []string{
+		"webrpc = v1\nname = example\n",
+		"\nstruct User\n  - id: uint64\n  # floating\n",
+		"\n# doc\nservice API\n  - Get(\n      error: string\n    )\n# ridlfmt:off\nerror 1   A   \"a\"\n# ridlfmt:on\n",
+		"error 2 B \"b\"\n\nerror 3 C \"c\"\n",
+		"enum Kind: uint32",
+	}, chunks)
+	require.Equal(t, []int{1, 3, 7, 16, 19}, lines)
+}
@@ -0,0 +1,160 @@
+package ast
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// DeclReader splits a RIDL document into chunks of whole top-level
+// declarations, so it can be parsed and printed one piece at a time with
+// memory bounded by the largest declaration. The header lines and runs of
+// errors stay in one chunk, as do ridlfmt:off regions and the blank lines and
+// comments before a declaration. Line breaks are returned as "\n".
+type DeclReader struct {
+	r    *bufio.Reader
+	err  error
+	read bool
+	crlf bool
+
+	// lines are read but not returned yet, the first one starting at start.
+	lines []chunkLine
+	start Position
+
+	// keyword starts the last declaration read, open is the number of
+	// parentheses left open by the current method and off is set inside a
+	// ridlfmt:off region.
+	keyword string
+	open    int
+	off     bool
+}
+
+type chunkLine struct {
+	text    string
+	blank   bool
+	comment bool
+}
+
+func NewDeclReader(r *bufio.Reader) *DeclReader {
+	return &DeclReader{
+		r:     r,
+		start: Position{Line: 1, Column: 1},
+	}
+}
+
+// CRLF reports whether the first line read ended with CRLF.
+func (d *DeclReader) CRLF() bool {
+	return d.crlf
+}
+
+// Next returns the next chunk and the position of its first byte in the
+// document. It returns io.EOF after the last chunk.
+func (d *DeclReader) Next() (string, Position, error) {
+	for d.err == nil {
+		text, err := d.r.ReadString('\n')
+		if err != nil {
+			d.err = err
+			if text == "" {
+				break
+			}
+		}
+
+		if strings.HasSuffix(text, "\r\n") {
+			text = text[:len(text)-2] + "\n"
+			d.crlf = d.crlf || !d.read
+		}
+		d.read = true
+
+		var l line
+		if lines := splitLines(Lex(text)); len(lines) != 0 {
+			l = lines[0]
+		}
+
+		if cut := d.add(text, l); cut > 0 {
+			return d.emit(cut)
+		}
+	}
+
+	if d.err != io.EOF {
+		return "", Position{}, d.err
+	}
+
+	if len(d.lines) == 0 {
+		return "", d.start, io.EOF
+	}
+
+	return d.emit(len(d.lines))
+}
+
+// add appends a line read and returns the number of lines to return before
+// it, or 0 if it doesn't start a new chunk.
+func (d *DeclReader) add(text string, l line) int {
+	var cut int
+	movable := true
+
+	switch {
+	case d.off:
+		d.off = !l.isDirective("on")
+		movable = false
+	case l.isDirective("off"):
+		d.off = true
+		movable = false
+	case l.isDecl() && (d.open <= 0 || l.start.Column == 1):
+		keyword := l.tokens[0].Text
+		if d.keyword != "" && !grouped(d.keyword, keyword) {
+			cut = d.docStart()
+		}
+		d.keyword = keyword
+		d.open = 0
+	case len(l.tokens) != 0 && l.tokens[0].Kind == TokenDash:
+		d.open = depth(l.tokens)
+	default:
+		d.open += depth(l.tokens)
+	}
+
+	d.lines = append(d.lines, chunkLine{
+		text:    text,
+		blank:   movable && l.isBlank(),
+		comment: movable && len(l.tokens) == 0 && l.comment != nil,
+	})
+
+	return cut
+}
+
+// docStart returns the index of the first of the blank lines and comments at
+// the end of d.lines that belong to the declaration that follows them.
+func (d *DeclReader) docStart() int {
+	i := len(d.lines)
+	for i > 0 && d.lines[i-1].comment {
+		i--
+	}
+	for i > 0 && d.lines[i-1].blank {
+		i--
+	}
+
+	return i
+}
+
+func (d *DeclReader) emit(n int) (string, Position, error) {
+	var sb strings.Builder
+	for _, l := range d.lines[:n] {
+		sb.WriteString(l.text)
+	}
+
+	start := d.start
+	d.start.Line += n
+	d.start.Offset += sb.Len()
+	d.lines = append(d.lines[:0], d.lines[n:]...)
+
+	return sb.String(), start, nil
+}
+
+// grouped reports whether declarations starting with keywords a and b are
+// printed as one block: the header lines, or a table of errors.
+func grouped(a, b string) bool {
+	return (isHeader(a) && isHeader(b)) || (a == "error" && b == "error")
+}
+
+func isHeader(keyword string) bool {
+	return keyword == "webrpc" || keyword == "name" || keyword == "version"
+}
@@ -0,0 +1,78 @@
+package ast
+
+import "fmt"
+
+type Position struct {
+	Offset int
+	Line   int
+	Column int
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+type TokenKind int
+
+const (
+	TokenEOF TokenKind = iota
+	TokenIllegal
+	TokenNewline
+	TokenComment
+	TokenWord
+	TokenString
+	TokenDash
+	TokenPlus
+	TokenAt
+	TokenAssign
+	TokenArrow
+	TokenColon
+	TokenComma
+	TokenLParen
+	TokenRParen
+	TokenLAngle
+	TokenRAngle
+	TokenLBrack
+	TokenRBrack
+)
+
+var tokenNames = map[TokenKind]string{
+	TokenEOF:     "EOF",
+	TokenIllegal: "illegal",
+	TokenNewline: "newline",
+	TokenComment: "comment",
+	TokenWord:    "word",
+	TokenString:  "string",
+	TokenDash:    "'-'",
+	TokenPlus:    "'+'",
+	TokenAt:      "'@'",
+	TokenAssign:  "'='",
+	TokenArrow:   "'=>'",
+	TokenColon:   "':'",
+	TokenComma:   "','",
+	TokenLParen:  "'('",
+	TokenRParen:  "')'",
+	TokenLAngle:  "'<'",
+	TokenRAngle:  "'>'",
+	TokenLBrack:  "'['",
+	TokenRBrack:  "']'",
+}
+
+func (k TokenKind) String() string {
+	if s, ok := tokenNames[k]; ok {
+		return s
+	}
+
+	return fmt.Sprintf("token(%d)", int(k))
+}
+
+type Token struct {
+	Kind TokenKind
+	Text string
+	Pos  Position
+}
+
+// End returns the byte offset just past the token.
+func (t Token) End() int {
+	return t.Pos.Offset + len(t.Text)
+}
@@ -46,17 +46,6 @@ func parseComment(s string) *comment {
 	return nil
 }
 
-func parseAndDivideInlineComment(s string) (string, *comment) {
-	c := parseComment(s)
-	if c != nil {
-		s = strings.TrimSuffix(s, c.original)
-		s = s[:len(s)-1]
-		s = strings.TrimRight(s, " ")
-	}
-
-	return s, c
-}
-
 func (c comment) getString() string {
 	var s string
 	if c.hidden {
@@ -0,0 +1,99 @@
+package formatter
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/webrpc/ridlfmt/formatter/ast"
+)
+
+type Severity int
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityWarning:
+		return "warning"
+	default:
+		return "error"
+	}
+}
+
+// Diagnostic describes a problem found in the input, located by line and
+// column (both 1-based). Snippet is the offending source line.
+type Diagnostic struct {
+	Pos      ast.Position
+	Severity Severity
+	Message  string
+	Snippet  string
+}
+
+func (d Diagnostic) Error() string {
+	msg := d.Message
+	if d.Severity == SeverityWarning {
+		msg = "warning: " + msg
+	}
+
+	if d.Pos.Line == 0 {
+		return msg
+	}
+
+	return fmt.Sprintf("%s: %s", d.Pos, msg)
+}
+
+// Diagnostics is returned as the error of Format when parts of the input
+// can't be formatted.
+type Diagnostics []Diagnostic
+
+func (d Diagnostics) Error() string {
+	lines := make([]string, len(d))
+	for i, diag := range d {
+		lines[i] = diag.Error()
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// newDiagnostics converts the errors of parsing src, which starts at start
+// in the document.
+func newDiagnostics(src string, start ast.Position, err error) Diagnostics {
+	var errList ast.ErrorList
+	if !errors.As(err, &errList) {
+		return Diagnostics{{Severity: SeverityError, Message: err.Error()}}
+	}
+
+	diags := make(Diagnostics, len(errList))
+	for i, e := range errList {
+		diags[i] = Diagnostic{
+			Pos:      shiftPosition(e.Pos, start),
+			Severity: SeverityError,
+			Message:  e.Msg,
+			Snippet:  sourceLine(src, e.Pos.Line),
+		}
+	}
+
+	return diags
+}
+
+// shiftPosition converts pos, found in a chunk of the document starting at
+// start, to a position in the whole document.
+func shiftPosition(pos ast.Position, start ast.Position) ast.Position {
+	pos.Line += start.Line - 1
+	pos.Offset += start.Offset
+
+	return pos
+}
+
+func sourceLine(src string, line int) string {
+	lines := strings.SplitN(src, "\n", line+1)
+	if line < 1 || line > len(lines) {
+		return ""
+	}
+
+	return strings.TrimRight(lines[line-1], " \t\r")
+}
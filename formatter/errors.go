@@ -2,6 +2,7 @@ package formatter
 
 import (
 	"fmt"
+	"strings"
 )
 
 type ridlError struct {
@@ -9,6 +10,7 @@ type ridlError struct {
 	name          string
 	description   string
 	httpCode      int
+	hasHTTPCode   bool
 	inlineComment *comment
 }
 
@@ -18,6 +20,64 @@ func (e ridlErrors) Len() int           { return len(e) }
 func (e ridlErrors) Less(i, j int) bool { return e[i].code < e[j].code }
 func (e ridlErrors) Swap(i, j int)      { e[i], e[j] = e[j], e[i] }
 
+// ErrorSortKey is a column errors can be sorted by.
+type ErrorSortKey string
+
+const (
+	ErrorSortCode ErrorSortKey = "code"
+	ErrorSortName ErrorSortKey = "name"
+	ErrorSortHTTP ErrorSortKey = "http"
+)
+
+// ParseErrorSortKeys parses a comma-separated list of error sort keys, such
+// as "http,code".
+func ParseErrorSortKeys(s string) ([]ErrorSortKey, error) {
+	var keys []ErrorSortKey
+	for _, k := range strings.Split(s, ",") {
+		switch key := ErrorSortKey(strings.TrimSpace(k)); key {
+		case ErrorSortCode, ErrorSortName, ErrorSortHTTP:
+			keys = append(keys, key)
+		default:
+			return nil, fmt.Errorf("unknown error sort key %q, expected code, name or http", k)
+		}
+	}
+
+	return keys, nil
+}
+
+// errorsByKeys sorts errors by each key in turn, the next key breaking ties
+// of the previous one. Errors without an HTTP status sort after the others.
+type errorsByKeys struct {
+	ridlErrors
+	keys []ErrorSortKey
+}
+
+func (e errorsByKeys) Less(i, j int) bool {
+	a, b := e.ridlErrors[i], e.ridlErrors[j]
+
+	for _, key := range e.keys {
+		switch key {
+		case ErrorSortCode:
+			if a.code != b.code {
+				return a.code < b.code
+			}
+		case ErrorSortName:
+			if a.name != b.name {
+				return a.name < b.name
+			}
+		case ErrorSortHTTP:
+			if a.hasHTTPCode != b.hasHTTPCode {
+				return a.hasHTTPCode
+			}
+			if a.httpCode != b.httpCode {
+				return a.httpCode < b.httpCode
+			}
+		}
+	}
+
+	return false
+}
+
 func (e ridlErrors) getLenghts() (codeLen, nameLen, descLen, httpLen int) {
 	for _, err := range e {
 		if len(fmt.Sprintf("%d", err.code)) > codeLen {
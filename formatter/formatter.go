@@ -1,21 +1,177 @@
 package formatter
 
 import (
+	"bufio"
+	"bytes"
+	"errors"
 	"fmt"
 	"io"
+	"strings"
+
+	"github.com/webrpc/ridlfmt/formatter/ast"
 )
 
+// Format formats a RIDL document with the default options, optionally sorting
+// errors by code.
 func Format(inputFile io.Reader, sortErrors bool) (string, error) {
+	opts := DefaultOptions()
+	opts.SortErrors = sortErrors
+
+	return FormatWithOptions(inputFile, opts)
+}
+
+// FormatWithOptions formats a RIDL document. When parts of it can't be parsed,
+// the error is a Diagnostics listing every problem and the output still holds
+// the formatted document with the unparseable regions left untouched. A UTF-8
+// byte order mark at the start of the input is kept.
+func FormatWithOptions(inputFile io.Reader, opts Options) (string, error) {
+	var output strings.Builder
+	err := format(&output, inputFile, opts)
+
+	return output.String(), err
+}
+
+// FormatTo formats a RIDL document read from r and writes it to w, reporting
+// errors the same way as FormatWithOptions. The input is read, parsed and
+// printed one top-level declaration at a time, so memory is bounded by the
+// largest declaration, or table of errors, rather than by the whole input.
+func FormatTo(w io.Writer, r io.Reader, opts Options) error {
+	bw := bufio.NewWriter(w)
+	err := format(bw, r, opts)
+
+	if flushErr := bw.Flush(); flushErr != nil {
+		return fmt.Errorf("writing output: %w", flushErr)
+	}
+
+	return err
+}
+
+// FormatBytes formats src with the default options. Like go/format.Source,
+// it returns no output when src can't be parsed.
+func FormatBytes(src []byte) ([]byte, error) {
+	var output bytes.Buffer
+	output.Grow(len(src))
+	if err := format(&output, bytes.NewReader(src), DefaultOptions()); err != nil {
+		return nil, err
+	}
+
+	return output.Bytes(), nil
+}
+
+// ErrNotIdempotent is returned by Verify when formatting its own output
+// changes it.
+var ErrNotIdempotent = errors.New("formatting is not idempotent")
+
+// Verify formats input twice and returns the formatted output. It fails with
+// an error wrapping ErrNotIdempotent when the second pass changes the output
+// of the first one.
+func Verify(input []byte, opts Options) ([]byte, error) {
+	var first bytes.Buffer
+	if err := format(&first, bytes.NewReader(input), opts); err != nil {
+		return first.Bytes(), err
+	}
+
+	// Warnings were reported by the first pass already.
+	opts.Warn = nil
+
+	var second bytes.Buffer
+	if err := format(&second, bytes.NewReader(first.Bytes()), opts); err != nil {
+		return first.Bytes(), fmt.Errorf("%w: formatted output doesn't parse: %v", ErrNotIdempotent, err)
+	}
+
+	if line := firstDifference(first.String(), second.String()); line != 0 {
+		return first.Bytes(), fmt.Errorf("%w: line %d changes when formatted again", ErrNotIdempotent, line)
+	}
+
+	return first.Bytes(), nil
+}
+
+// firstDifference returns the 1-based number of the first line that differs
+// between a and b, or 0 if they are equal.
+func firstDifference(a, b string) int {
+	if a == b {
+		return 0
+	}
+
+	linesA := strings.SplitAfter(a, "\n")
+	linesB := strings.SplitAfter(b, "\n")
+	for i := range linesA {
+		if i >= len(linesB) || linesA[i] != linesB[i] {
+			return i + 1
+		}
+	}
+
+	return len(linesA) + 1
+}
+
+// format writes the formatted input to w as it's printed, one top-level
+// declaration at a time.
+func format(w io.Writer, r io.Reader, opts Options) error {
+	if err := opts.validate(); err != nil {
+		return fmt.Errorf("invalid options: %w", err)
+	}
+
+	br := bufio.NewReader(r)
+
+	prefix, err := br.Peek(len(utf8BOM))
+	bom := err == nil && string(prefix) == utf8BOM
+	if bom {
+		_, err = br.Discard(len(utf8BOM))
+	}
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("reading input file: %w", err)
+	}
+
+	decls := ast.NewDeclReader(br)
+	src, start, err := decls.Next()
+
 	f := form{
-		sortErrors: sortErrors,
+		opts:    opts,
+		w:       w,
+		newline: "\n",
 	}
+	if opts.LineEnding == LineEndingCRLF || (opts.LineEnding == LineEndingAuto && decls.CRLF()) {
+		f.newline = "\r\n"
+	}
+
+	if bom {
+		f.write(utf8BOM)
+	}
+
+	var diags Diagnostics
+	var prev ast.Node
+	for ; err == nil; src, start, err = decls.Next() {
+		file, parseErr := ast.Parse(src)
+
+		for _, w := range sortFile(file, opts) {
+			if opts.Warn != nil {
+				w.Snippet = sourceLine(src, w.Pos.Line)
+				w.Pos = shiftPosition(w.Pos, start)
+				opts.Warn(w)
+			}
+		}
+
+		prev = spaceFile(file, opts, prev)
+		f.printFile(file)
 
-	output, err := f.processLines(inputFile)
-	if err != nil {
-		return "", fmt.Errorf("process lines: %w", err)
+		if parseErr != nil {
+			diags = append(diags, newDiagnostics(src, start, parseErr)...)
+		}
 	}
 
-	output = f.removeDoubleLines(output)
+	if err != io.EOF {
+		return fmt.Errorf("reading input file: %w", err)
+	}
+
+	if f.err != nil {
+		return fmt.Errorf("writing output: %w", f.err)
+	}
 
-	return output, nil
+	if len(diags) != 0 {
+		return diags
+	}
+
+	return nil
 }
+
+const utf8BOM = "\ufeff"
@@ -0,0 +1,677 @@
+package formatter
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatDiagnostics(t *testing.T) {
+	_, err := Format(strings.NewReader("webrpc = v1\n\nstruct User\n  - id uint64   \n"), false)
+
+	var diags Diagnostics
+	require.ErrorAs(t, err, &diags)
+	require.Len(t, diags, 1)
+	require.Equal(t, 4, diags[0].Pos.Line)
+	require.Equal(t, 3, diags[0].Pos.Column)
+	require.Equal(t, SeverityError, diags[0].Severity)
+	require.Equal(t, "  - id uint64", diags[0].Snippet)
+	require.EqualError(t, err, "4:3: expected ':' in struct field")
+}
+
+func TestFormatRecovery(t *testing.T) {
+	input := `webrpc   = v1
+
+struct User
+  - id uint64
+    +   json = id
+
+struct   Empty
+enum Kind uint32 = x
+  - A
+error 1 Oops "oops" HTTP
+`
+
+	output, err := Format(strings.NewReader(input), false)
+
+	var diags Diagnostics
+	require.ErrorAs(t, err, &diags)
+	require.Len(t, diags, 3)
+	require.Equal(t, "4:3: expected ':' in struct field", diags[0].Error())
+	require.Equal(t, "8:11: expected ':' after enum name", diags[1].Error())
+	require.Equal(t, 10, diags[2].Pos.Line)
+
+	expected := `webrpc = v1
+
+struct User
+  - id uint64
+    +   json = id
+
+struct Empty
+enum Kind uint32 = x
+  - A
+error 1 Oops "oops" HTTP
+`
+	require.Equal(t, expected, output)
+	requireIdempotent(t, output, DefaultOptions())
+}
+
+func TestFormatConcurrent(t *testing.T) {
+	input := "webrpc   = v1\n\nerror 2 B \"b\" HTTP 400\nerror 1 A \"a\" HTTP 400\n"
+	expected, err := Format(strings.NewReader(input), true)
+	require.NoError(t, err)
+
+	outputs := make([]string, 16)
+	errs := make([]error, 16)
+
+	var wg sync.WaitGroup
+	for i := range outputs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			outputs[i], errs[i] = Format(strings.NewReader(input), true)
+		}(i)
+	}
+
+	wg.Wait()
+
+	for i := range outputs {
+		require.NoError(t, errs[i])
+		require.Equal(t, expected, outputs[i])
+	}
+}
+
+func TestFormatWithOptions(t *testing.T) {
+	input := `struct User
+  - id: uint64
+    + json = id
+
+error 1   A    "a"   HTTP 400
+error 100 Long "long" HTTP 404
+`
+
+	opts := DefaultOptions()
+	opts.UseTabs = true
+	opts.TagIndent = 5
+	opts.AlignErrors = false
+
+	output, err := FormatWithOptions(strings.NewReader(input), opts)
+	require.NoError(t, err)
+
+	expected := "struct User\n\t- id: uint64\n\t\t + json = id\n\n" +
+		"error 1 A \"a\" HTTP 400\nerror 100 Long \"long\" HTTP 404\n"
+	require.Equal(t, expected, output)
+	requireIdempotent(t, output, opts)
+}
+
+func TestFormatInvalidOptions(t *testing.T) {
+	opts := DefaultOptions()
+	opts.FieldIndent = -2
+
+	_, err := FormatWithOptions(strings.NewReader("struct User\n  - id: uint64\n"), opts)
+	require.EqualError(t, err, "invalid options: field indent must not be negative, got -2")
+
+	opts = DefaultOptions()
+	opts.TagIndent = -1
+
+	_, err = FormatWithOptions(strings.NewReader("struct User\n  - id: uint64\n    + json = id\n"), opts)
+	require.EqualError(t, err, "invalid options: tag indent must not be negative, got -1")
+}
+
+func TestFormatDirectives(t *testing.T) {
+	input := `struct User
+  - id:    uint64
+  # ridlfmt:off
+  - name:      string
+      + json   = name
+  # ridlfmt:on
+  - email:   string
+
+#ridlfmt:off
+error 1   A    "a"   HTTP 400
+error 100 Long "long" HTTP 404
+`
+
+	output, err := Format(strings.NewReader(input), true)
+	require.NoError(t, err)
+
+	expected := `struct User
+  - id: uint64
+  # ridlfmt:off
+  - name:      string
+      + json   = name
+  # ridlfmt:on
+  - email: string
+
+#ridlfmt:off
+error 1   A    "a"   HTTP 400
+error 100 Long "long" HTTP 404
+`
+	require.Equal(t, expected, output)
+	requireIdempotent(t, output, sortedOptions())
+}
+
+func TestFormatDirectivesInTags(t *testing.T) {
+	input := `struct User
+  - id: uint64
+    +   json = id
+    # ridlfmt:off
+    + go.tag.db   = id
+    + go.tag.gorm = pk
+    # ridlfmt:on
+    +   go.field.name = ID
+  - name:   string
+`
+
+	output, err := Format(strings.NewReader(input), false)
+	require.NoError(t, err)
+
+	expected := `struct User
+  - id: uint64
+    + json = id
+    # ridlfmt:off
+    + go.tag.db   = id
+    + go.tag.gorm = pk
+    # ridlfmt:on
+    + go.field.name = ID
+  - name: string
+`
+	require.Equal(t, expected, output)
+	requireIdempotent(t, output, sortedOptions())
+}
+
+func TestFormatAlignFields(t *testing.T) {
+	input := `struct User
+  - id: uint64 # primary key
+  - age: int # years
+  - createdAt: timestamp
+    + json = created_at
+
+  # contact
+  - email: string
+  - phoneNumber: string # optional
+`
+
+	opts := DefaultOptions()
+	opts.AlignFields = true
+	opts.AlignFieldComments = true
+
+	output, err := FormatWithOptions(strings.NewReader(input), opts)
+	require.NoError(t, err)
+
+	expected := `struct User
+  - id:        uint64 # primary key
+  - age:       int    # years
+  - createdAt: timestamp
+    + json = created_at
+
+  # contact
+  - email:       string
+  - phoneNumber: string # optional
+`
+	require.Equal(t, expected, output)
+	requireIdempotent(t, output, opts)
+}
+
+func TestFormatAlignComments(t *testing.T) {
+	input := `webrpc = v1 # schema version
+name = example # service name
+version = v0.1.0 # api version
+
+enum Kind: uint32
+  - USER # a user
+  - ADMINISTRATOR # can do anything
+  - A_VERY_LONG_ENUM_VALUE_NAME_THAT_GOES_ON # too long
+
+  - GUEST # separate block
+`
+
+	opts := DefaultOptions()
+	opts.AlignComments = true
+	opts.MaxCommentColumn = 24
+
+	output, err := FormatWithOptions(strings.NewReader(input), opts)
+	require.NoError(t, err)
+
+	expected := `webrpc = v1      # schema version
+name = example   # service name
+version = v0.1.0 # api version
+
+enum Kind: uint32
+  - USER          # a user
+  - ADMINISTRATOR # can do anything
+  - A_VERY_LONG_ENUM_VALUE_NAME_THAT_GOES_ON # too long
+
+  - GUEST # separate block
+`
+	require.Equal(t, expected, output)
+	requireIdempotent(t, output, opts)
+}
+
+func TestFormatAlignTags(t *testing.T) {
+	input := `struct User
+  - id: uint64
+    + json = id
+    + go.field.name = ID
+    + go.tag.db = id
+    + go.tag.json
+    # storage
+    + go.tag.gorm = primaryKey
+`
+
+	opts := DefaultOptions()
+	opts.AlignTags = true
+
+	output, err := FormatWithOptions(strings.NewReader(input), opts)
+	require.NoError(t, err)
+
+	expected := `struct User
+  - id: uint64
+    + json          = id
+    + go.field.name = ID
+    + go.tag.db     = id
+    + go.tag.json
+    # storage
+    + go.tag.gorm = primaryKey
+`
+	require.Equal(t, expected, output)
+	requireIdempotent(t, output, opts)
+}
+
+func TestFormatWrapMethods(t *testing.T) {
+	input := `service API
+  - GetUser(header: map<string,string>, userID: uint64) => (code: uint32, user: User) # long
+  - Ping() => (ok: bool)
+  - stream Watch(filter: string) => stream ()
+  - ListEverythingAtOnce() => (users: []User)
+`
+
+	opts := DefaultOptions()
+	opts.MaxWidth = 40
+
+	output, err := FormatWithOptions(strings.NewReader(input), opts)
+	require.NoError(t, err)
+
+	expected := `service API
+  - GetUser(
+      header: map<string,string>,
+      userID: uint64
+    ) => (
+      code: uint32,
+      user: User
+    ) # long
+  - Ping() => (ok: bool)
+  - stream Watch(
+      filter: string
+    ) => stream ()
+  - ListEverythingAtOnce() => (
+      users: []User
+    )
+`
+	require.Equal(t, expected, output)
+	requireIdempotent(t, output, opts)
+
+	again, err := FormatWithOptions(strings.NewReader(output), opts)
+	require.NoError(t, err)
+	require.Equal(t, output, again)
+
+	output, err = FormatWithOptions(strings.NewReader(output), DefaultOptions())
+	require.NoError(t, err)
+	require.Equal(t, input, output)
+}
+
+func TestFormatWrapKeywordArguments(t *testing.T) {
+	input := `service API
+  - FindUserByName(name: string, error: string) => (user: User, version: string)
+`
+
+	opts := DefaultOptions()
+	opts.MaxWidth = 30
+
+	output, err := FormatWithOptions(strings.NewReader(input), opts)
+	require.NoError(t, err)
+
+	expected := `service API
+  - FindUserByName(
+      name: string,
+      error: string
+    ) => (
+      user: User,
+      version: string
+    )
+`
+	require.Equal(t, expected, output)
+	requireIdempotent(t, output, opts)
+}
+
+func TestFormatMultiLineMethods(t *testing.T) {
+	input := `service API
+  - Get(
+      a: uint64,
+      b: string,
+    )
+    => (u: User)
+  - Find( # request
+      a: uint64, # id
+      b: string
+    ) => stream (u: User)
+  - Put(a: int) =>
+      (b: int)
+  - Watch(
+      a: int
+    ) => stream
+    (b: int)
+`
+
+	output, err := Format(strings.NewReader(input), false)
+	require.NoError(t, err)
+
+	expected := `service API
+  - Get(a: uint64, b: string) => (u: User)
+  - Find(
+      # request
+      a: uint64, # id
+      b: string
+    ) => stream (
+      u: User
+    )
+  - Put(a: int) => (b: int)
+  - Watch(a: int) => stream (b: int)
+`
+	require.Equal(t, expected, output)
+	requireIdempotent(t, output, DefaultOptions())
+}
+
+func TestFormatMultiLineMethodComments(t *testing.T) {
+	// A comment after the method name isn't moved into it.
+	input := "service API\n  - Put # note\n    => (x: int)\n"
+
+	output, err := Format(strings.NewReader(input), false)
+	require.EqualError(t, err, "2:3: missing '(' in method")
+	require.Equal(t, input, output)
+	requireIdempotent(t, output, DefaultOptions())
+
+	output, err = Format(strings.NewReader("service API\n  - Get(a: int) # note\n    => (x: int)\n"), false)
+	require.NoError(t, err)
+	require.Equal(t, "service API\n  - Get(\n      a: int # note\n    ) => (\n      x: int\n    )\n", output)
+	requireIdempotent(t, output, DefaultOptions())
+}
+
+func TestFormatSort(t *testing.T) {
+	input := `import
+  - zeta.ridl
+  - alpha.ridl
+
+  - beta.ridl
+
+enum Kind: uint32
+  - USER
+  - ADMIN
+
+struct User
+  - id: uint64
+    + json = id
+    + go.tag.db = id
+    # gorm
+    + go.tag.gorm = pk
+
+service API
+  @deprecated @auth:"ApiKey"
+  - Get()
+  @internal
+  @deprecated
+  @auth:"ApiKey"
+  - Put()
+`
+
+	opts := DefaultOptions()
+	opts.SortImports = true
+	opts.SortTags = true
+	opts.SortAnnotations = true
+	opts.SortEnumValues = true
+
+	var warnings []Diagnostic
+	opts.Warn = func(d Diagnostic) {
+		warnings = append(warnings, d)
+	}
+
+	output, err := FormatWithOptions(strings.NewReader(input), opts)
+	require.NoError(t, err)
+
+	expected := `import
+  - alpha.ridl
+  - zeta.ridl
+
+  - beta.ridl
+
+enum Kind: uint32
+  - ADMIN
+  - USER
+
+struct User
+  - id: uint64
+    + go.tag.db = id
+    + json = id
+    # gorm
+    + go.tag.gorm = pk
+
+service API
+    @auth:"ApiKey" @deprecated
+  - Get()
+    @auth:"ApiKey"
+    @deprecated
+    @internal
+  - Put()
+`
+	require.Equal(t, expected, output)
+	requireIdempotent(t, output, opts)
+
+	require.Len(t, warnings, 1)
+	require.Equal(t, SeverityWarning, warnings[0].Severity)
+	require.Equal(t, "7:1: warning: enum Kind has values without an explicit number, sorting them changes their numbers", warnings[0].Error())
+}
+
+func TestFormatBlankLines(t *testing.T) {
+	input := `
+webrpc = v1
+
+name = example
+version = v0.1.0
+enum Kind: uint32
+
+  - USER
+  - ADMIN
+struct User
+  - id: uint64
+    + json = id
+  - name: string
+  - email: string
+
+  # floating
+
+
+error 1 A "a"
+error 2 B "b"
+`
+
+	opts := DefaultOptions()
+	opts.SeparateDecls = true
+	opts.SeparateTaggedFields = true
+	opts.CompactHeader = true
+	opts.TrimBlocks = true
+
+	output, err := FormatWithOptions(strings.NewReader(input), opts)
+	require.NoError(t, err)
+
+	expected := `webrpc = v1
+name = example
+version = v0.1.0
+
+enum Kind: uint32
+  - USER
+  - ADMIN
+
+struct User
+  - id: uint64
+    + json = id
+
+  - name: string
+  - email: string
+
+  # floating
+
+error 1 A "a"
+error 2 B "b"
+`
+	require.Equal(t, expected, output)
+	requireIdempotent(t, output, opts)
+}
+
+func TestFormatLineEndings(t *testing.T) {
+	input := "\ufeffwebrpc   = v1\r\n\r\nstruct User\r\n  - id:   uint64 # id\r\n"
+
+	output, err := Format(strings.NewReader(input), false)
+	require.NoError(t, err)
+	require.Equal(t, "\ufeffwebrpc = v1\r\n\r\nstruct User\r\n  - id: uint64 # id\r\n", output)
+	requireIdempotent(t, output, DefaultOptions())
+
+	opts := DefaultOptions()
+	opts.LineEnding = LineEndingLF
+
+	output, err = FormatWithOptions(strings.NewReader(input), opts)
+	require.NoError(t, err)
+	require.Equal(t, "\ufeffwebrpc = v1\n\nstruct User\n  - id: uint64 # id\n", output)
+	requireIdempotent(t, output, opts)
+
+	opts.LineEnding = LineEndingCRLF
+
+	output, err = FormatWithOptions(strings.NewReader("webrpc = v1\n"), opts)
+	require.NoError(t, err)
+	require.Equal(t, "webrpc = v1\r\n", output)
+	requireIdempotent(t, output, opts)
+}
+
+func TestFormatLongLines(t *testing.T) {
+	comment := "# " + strings.Repeat("x", 200*1024)
+	values := make([]string, 20000)
+	for i := range values {
+		values[i] = fmt.Sprintf("v%d: uint64", i)
+	}
+	method := "- Get(" + strings.Join(values, ", ") + ")"
+
+	input := "webrpc   = v1 " + comment + "\n\nservice API\n  " + method + "\n"
+
+	output, err := Format(strings.NewReader(input), false)
+	require.NoError(t, err)
+	require.Equal(t, "webrpc = v1 "+comment+"\n\nservice API\n  "+method+"\n", output)
+	requireIdempotent(t, output, DefaultOptions())
+}
+
+func TestFormatTo(t *testing.T) {
+	input := "webrpc   = v1\n\nstruct User\n  - id:   uint64 # id\n    +   json = id\n\nerror 1 A   \"a\"\n"
+
+	var output strings.Builder
+	err := FormatTo(&output, strings.NewReader(input), DefaultOptions())
+	require.NoError(t, err)
+
+	expected, err := FormatWithOptions(strings.NewReader(input), DefaultOptions())
+	require.NoError(t, err)
+	require.Equal(t, expected, output.String())
+	requireIdempotent(t, output.String(), DefaultOptions())
+
+	formatted, err := FormatBytes([]byte(input))
+	require.NoError(t, err)
+	require.Equal(t, expected, string(formatted))
+
+	output.Reset()
+	err = FormatTo(&output, strings.NewReader("struct User\n  - id uint64\n"), DefaultOptions())
+	require.EqualError(t, err, "2:3: expected ':' in struct field")
+	require.Equal(t, "struct User\n  - id uint64\n", output.String())
+
+	formatted, err = FormatBytes([]byte("struct User\n  - id uint64\n"))
+	require.EqualError(t, err, "2:3: expected ':' in struct field")
+	require.Nil(t, formatted)
+}
+
+func TestFormatToStreams(t *testing.T) {
+	var input strings.Builder
+	for i := 0; i < 2000; i++ {
+		fmt.Fprintf(&input, "\nstruct   S%d\n  - id:   uint64\n", i)
+	}
+
+	// The output starts before most of the input is read.
+	r := &countingReader{r: strings.NewReader(input.String())}
+	readAtFirstWrite := -1
+	var output strings.Builder
+	w := writerFunc(func(p []byte) (int, error) {
+		if readAtFirstWrite == -1 {
+			readAtFirstWrite = r.n
+		}
+		return output.Write(p)
+	})
+
+	require.NoError(t, FormatTo(w, r, DefaultOptions()))
+	require.Less(t, readAtFirstWrite, input.Len()/4)
+
+	expected := strings.ReplaceAll(strings.ReplaceAll(input.String(), "struct   ", "struct "), "id:   ", "id: ")
+	require.Equal(t, expected, output.String())
+
+	// Positions are counted from the start of the document.
+	_, err := FormatWithOptions(strings.NewReader(input.String()+"struct User\n  - id uint64\n"), DefaultOptions())
+	require.EqualError(t, err, "6002:3: expected ':' in struct field")
+}
+
+func TestVerify(t *testing.T) {
+	output, err := Verify([]byte("webrpc   = v1\n"), DefaultOptions())
+	require.NoError(t, err)
+	require.Equal(t, "webrpc = v1\n", string(output))
+
+	require.Equal(t, 0, firstDifference("a\nb\n", "a\nb\n"))
+	require.Equal(t, 2, firstDifference("a\nb\n", "a\nc\n"))
+	require.Equal(t, 3, firstDifference("a\nb\n", "a\nb\n\n"))
+}
+
+func sortedOptions() Options {
+	opts := DefaultOptions()
+	opts.SortErrors = true
+
+	return opts
+}
+
+// requireIdempotent checks that formatting output again doesn't change it,
+// including the regions that can't be parsed.
+func requireIdempotent(t *testing.T, output string, opts Options) {
+	t.Helper()
+
+	opts.Warn = nil
+
+	again, err := FormatWithOptions(strings.NewReader(output), opts)
+	if err != nil {
+		var diags Diagnostics
+		require.ErrorAs(t, err, &diags)
+	}
+	require.Equal(t, output, again)
+}
+
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+
+	return n, err
+}
+
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	return f(p)
+}
@@ -0,0 +1,116 @@
+package formatter
+
+import "fmt"
+
+// Options controls the layout produced by FormatWithOptions. Start from
+// DefaultOptions and change what differs, the zero value isn't useful.
+type Options struct {
+	// FieldIndent is the indentation, in columns, of struct fields, enum
+	// values, service methods and import members.
+	FieldIndent int
+
+	// TagIndent is the indentation, in columns, of field tags and method
+	// annotations.
+	TagIndent int
+
+	// UseTabs indents with tabs, each one counting as TabWidth columns.
+	// Indentation that isn't a multiple of TabWidth is padded with spaces.
+	UseTabs  bool
+	TabWidth int
+
+	// SortErrors sorts each block of errors by code, or by ErrorSortKeys
+	// when set.
+	SortErrors    bool
+	ErrorSortKeys []ErrorSortKey
+
+	// SortImports, SortTags and SortAnnotations sort import members, struct
+	// field tags and method annotations by name. Blank lines and comments
+	// start a new block that is sorted on its own.
+	SortImports     bool
+	SortTags        bool
+	SortAnnotations bool
+
+	// SortEnumValues sorts enum values by name. Values without an explicit
+	// number are renumbered by this, which is reported through Warn.
+	SortEnumValues bool
+
+	// SeparateDecls puts exactly one blank line between top-level
+	// declarations. Header lines and errors that follow each other keep the
+	// blank lines they have.
+	SeparateDecls bool
+
+	// SeparateTaggedFields puts one blank line between a struct field with
+	// tags and the fields next to it.
+	SeparateTaggedFields bool
+
+	// CompactHeader removes the blank lines between the webrpc, name and
+	// version lines.
+	CompactHeader bool
+
+	// TrimBlocks removes the blank lines at the start of enums, structs,
+	// services, imports and the tags of a field.
+	TrimBlocks bool
+
+	// LineEnding selects the line endings of the output. LineEndingAuto
+	// keeps the ones of the input, detected from its first line.
+	LineEnding LineEnding
+
+	// Warn is called with the warnings found while formatting, if set.
+	Warn func(Diagnostic)
+
+	// AlignErrors aligns each block of errors into columns.
+	AlignErrors bool
+
+	// AlignFields aligns the types of consecutive struct fields into a
+	// column. Blank lines and comments start a new alignment block.
+	AlignFields bool
+
+	// AlignFieldComments aligns the trailing comments of consecutive struct
+	// fields.
+	AlignFieldComments bool
+
+	// AlignTags aligns the `=` signs of the tags under each struct field.
+	AlignTags bool
+
+	// AlignComments aligns the trailing comments of consecutive lines in
+	// headers, enums, structs and services to a common column.
+	AlignComments bool
+
+	// MaxWidth is the line width past which service methods are wrapped
+	// with one argument per line. Zero means methods are never wrapped.
+	MaxWidth int
+
+	// MaxCommentColumn is the furthest column an aligned comment is moved
+	// to. Lines longer than that keep a single space before their comment.
+	// Zero means no limit.
+	MaxCommentColumn int
+}
+
+type LineEnding string
+
+const (
+	LineEndingAuto LineEnding = ""
+	LineEndingLF   LineEnding = "lf"
+	LineEndingCRLF LineEnding = "crlf"
+)
+
+func DefaultOptions() Options {
+	return Options{
+		FieldIndent: 2,
+		TagIndent:   4,
+		TabWidth:    2,
+		AlignErrors: true,
+	}
+}
+
+// validate reports options that can't be printed with.
+func (o Options) validate() error {
+	if o.FieldIndent < 0 {
+		return fmt.Errorf("field indent must not be negative, got %d", o.FieldIndent)
+	}
+	if o.TagIndent < 0 {
+		return fmt.Errorf("tag indent must not be negative, got %d", o.TagIndent)
+	}
+
+	return nil
+}
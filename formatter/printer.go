@@ -0,0 +1,556 @@
+package formatter
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/webrpc/ridlfmt/formatter/ast"
+)
+
+// form holds the printing state of a single Format call. A new one is created
+// per call, so Format is safe for concurrent use.
+type form struct {
+	opts      Options
+	padding   int
+	emptyLine bool
+
+	// w receives the output, with line breaks written as newline. The first
+	// write error is kept in err and stops the output.
+	w       io.Writer
+	newline string
+	err     error
+
+	// pending holds printed lines until the next blank line or top-level
+	// declaration, so trailing comments can be aligned across them.
+	pending []pendingLine
+
+	// fieldWidths is the width of the `name:` column of aligned fields.
+	fieldWidths map[*ast.Field]int
+
+	// tagWidths is the width of the key column of aligned tags.
+	tagWidths map[*ast.Tag]int
+}
+
+type pendingLine struct {
+	text    string
+	comment string
+	align   bool
+}
+
+func (f *form) printFile(file *ast.File) {
+	decls := file.Decls
+	for i := 0; i < len(decls); i++ {
+		if _, ok := decls[i].(*ast.Error); ok {
+			group := errorGroup(decls[i:])
+			f.printErrors(group)
+			i += len(group) - 1
+
+			continue
+		}
+
+		// Consecutive header lines form one block, any other declaration
+		// starts a new one.
+		_, isDef := decls[i].(*ast.Definition)
+		if i == 0 || !isDef || !isDefinition(decls[i-1]) {
+			f.flush()
+		}
+
+		f.printNode(decls[i])
+	}
+
+	f.flush()
+}
+
+func isDefinition(n ast.Node) bool {
+	_, ok := n.(*ast.Definition)
+	return ok
+}
+
+// errorGroup returns the leading run of errors in nodes that is printed as
+// one aligned table. A blank line or a comment starts a new table.
+func errorGroup(nodes []ast.Node) []*ast.Error {
+	var group []*ast.Error
+	for i, n := range nodes {
+		e, ok := n.(*ast.Error)
+		if !ok || (i > 0 && (e.EmptyLines > 0 || e.Doc != nil)) {
+			break
+		}
+
+		group = append(group, e)
+	}
+
+	return group
+}
+
+func (f *form) printNode(n ast.Node) {
+	switch n := n.(type) {
+	case *ast.CommentGroup:
+		f.printEmptyLines(n.EmptyLines)
+		f.commentsPrint(n, f.padding)
+	case *ast.Definition:
+		f.printLine(n, 0, fmt.Sprintf("%s = %s", n.Key, n.Value))
+	case *ast.Import:
+		line := "import"
+		if n.Path != "" {
+			line += " " + n.Path
+		}
+
+		f.printLine(n, 0, line)
+		f.printBody(n.Body)
+	case *ast.ImportMember:
+		line := "- " + n.Name
+		if n.Value != "" {
+			line += ": " + n.Value
+		}
+
+		f.printLine(n, f.opts.FieldIndent, line)
+	case *ast.Enum:
+		line := "enum " + n.Name
+		if n.Type != "" {
+			line += ": " + n.Type
+		}
+
+		f.printLine(n, 0, line)
+		f.printBody(n.Body)
+	case *ast.EnumValue:
+		line := "- " + n.Name
+		if n.Value != "" {
+			line += " = " + n.Value
+		}
+
+		f.printLine(n, f.opts.FieldIndent, line)
+	case *ast.Struct:
+		f.printLine(n, 0, "struct "+n.Name)
+		if f.opts.AlignFields {
+			f.fieldWidths = fieldWidths(n.Body)
+		}
+		f.printBody(n.Body)
+	case *ast.Field:
+		f.printLine(n, f.opts.FieldIndent, fmt.Sprintf("- %-*s %s", f.fieldWidths[n], n.Name+":", n.Type))
+		if f.opts.AlignTags {
+			f.tagWidths = tagWidths(n.Tags)
+		}
+		f.printBody(n.Tags)
+	case *ast.Tag:
+		line := "+ " + n.Key
+		if n.HasValue {
+			line = strings.TrimRight(fmt.Sprintf("+ %-*s = %s", f.tagWidths[n], n.Key, n.Value), " ")
+		}
+
+		f.printLine(n, f.opts.TagIndent, line)
+	case *ast.AnnotationLine:
+		annotations := make([]string, len(n.Annotations))
+		for i, a := range n.Annotations {
+			annotations[i] = "@" + a.Name
+			if a.HasValue {
+				annotations[i] += ":" + a.Value
+			}
+		}
+
+		f.printLine(n, f.opts.TagIndent, strings.Join(annotations, " "))
+	case *ast.Service:
+		f.printLine(n, 0, "service "+n.Name)
+		f.printBody(n.Body)
+	case *ast.Method:
+		line := formatMethod(n)
+		if hasArgumentComments(n) || (f.opts.MaxWidth > 0 && f.opts.FieldIndent+utf8.RuneCountInString(line) > f.opts.MaxWidth) {
+			f.printWrappedMethod(n, f.opts.FieldIndent)
+			break
+		}
+
+		f.printLine(n, f.opts.FieldIndent, line)
+	case *ast.Error:
+		f.printErrors([]*ast.Error{n})
+	case *ast.Bad:
+		f.printEmptyLines(n.EmptyLines)
+		f.writeRaw(n.Text)
+		f.padding = 0
+	case *ast.Verbatim:
+		f.printEmptyLines(n.EmptyLines)
+		f.writeRaw(n.Text)
+	}
+}
+
+func (f *form) printBody(nodes []ast.Node) {
+	for _, n := range nodes {
+		f.printNode(n)
+	}
+}
+
+func (f *form) printLine(n ast.Decorated, padding int, line string) {
+	comment := f.printDecoration(n, padding)
+
+	_, isField := n.(*ast.Field)
+	f.addLine(padding, line, comment, f.opts.AlignComments || (isField && f.opts.AlignFieldComments))
+}
+
+// printDecoration prints the blank line and doc comments before n and
+// returns its inline comment.
+func (f *form) printDecoration(n ast.Decorated, padding int) string {
+	d := n.Decor()
+
+	f.printEmptyLines(d.EmptyLines)
+	if d.Doc != nil {
+		f.commentsPrint(d.Doc, padding)
+	}
+
+	if d.Comment == nil {
+		return ""
+	}
+
+	return parseComment(d.Comment.Text).getString()
+}
+
+// printWrappedMethod prints a method too long for MaxWidth, or with comments
+// between its arguments, with one argument per line. The closing parentheses
+// line up with the method name.
+func (f *form) printWrappedMethod(m *ast.Method, padding int) {
+	comment := f.printDecoration(m, padding)
+
+	line := "- "
+	if m.StreamInput {
+		line += "stream "
+	}
+	line += m.Name + "("
+
+	// linePadding is the indentation of the line being built, which starts
+	// with the method itself and then with a closing parenthesis.
+	linePadding := padding
+	if len(m.Inputs) != 0 {
+		f.addLine(linePadding, line, "", false)
+		f.printArguments(m.Inputs, padding+4)
+		line = ")"
+		linePadding = padding + 2
+	} else {
+		line += ")"
+	}
+
+	if m.HasOutputs {
+		line += " => "
+		if m.StreamOutput {
+			line += "stream "
+		}
+
+		if len(m.Outputs) != 0 {
+			f.addLine(linePadding, line+"(", "", false)
+			f.printArguments(m.Outputs, padding+4)
+			line = ")"
+			linePadding = padding + 2
+		} else {
+			line += "()"
+		}
+	}
+
+	f.addLine(linePadding, line, comment, f.opts.AlignComments)
+}
+
+func (f *form) printArguments(args []*ast.Argument, padding int) {
+	for i, a := range args {
+		line := fmt.Sprintf("%s: %s", a.Name, a.Type)
+		if i < len(args)-1 {
+			line += ","
+		}
+
+		f.printLine(a, padding, line)
+	}
+}
+
+// hasArgumentComments reports whether m has comments between its arguments,
+// which can only be kept by wrapping it.
+func hasArgumentComments(m *ast.Method) bool {
+	for _, args := range [][]*ast.Argument{m.Inputs, m.Outputs} {
+		for _, a := range args {
+			if a.Doc != nil || a.Comment != nil {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (f *form) printEmptyLines(n int) {
+	if n > 0 && !f.emptyLine {
+		f.flush()
+		f.write("\n")
+		f.emptyLine = true
+	}
+}
+
+func (f *form) writeLine(padding int, line string) {
+	f.addLine(padding, line, "", false)
+}
+
+func (f *form) addLine(padding int, line string, comment string, align bool) {
+	f.pending = append(f.pending, pendingLine{
+		text:    f.indent(padding) + line,
+		comment: comment,
+		align:   align,
+	})
+	f.padding = padding
+	f.emptyLine = false
+}
+
+// writeRaw writes text from the input as it is.
+func (f *form) writeRaw(text string) {
+	f.flush()
+	f.write(text + "\n")
+	f.emptyLine = false
+}
+
+// flush writes the pending lines. Trailing comments of consecutive lines
+// that allow it are aligned to a common column, unless that column would be
+// past MaxCommentColumn. Lines too long for it keep a single space before the
+// comment.
+func (f *form) flush() {
+	for i := 0; i < len(f.pending); {
+		end := i + 1
+		width := 0
+		if f.pending[i].align && f.pending[i].comment != "" {
+			for end < len(f.pending) && f.pending[end].align && f.pending[end].comment != "" {
+				end++
+			}
+
+			for _, l := range f.pending[i:end] {
+				if n := utf8.RuneCountInString(l.text); f.fitsCommentColumn(n) {
+					width = maxInt(width, n)
+				}
+			}
+		}
+
+		for _, l := range f.pending[i:end] {
+			line := l.text
+			if l.comment != "" {
+				line += spaces(width-utf8.RuneCountInString(l.text)) + " " + l.comment
+			}
+			f.write(line + "\n")
+		}
+
+		i = end
+	}
+
+	f.pending = f.pending[:0]
+}
+
+// fitsCommentColumn reports whether a comment aligned after a line of width
+// n starts at or before MaxCommentColumn.
+func (f *form) fitsCommentColumn(n int) bool {
+	return f.opts.MaxCommentColumn <= 0 || n+1 <= f.opts.MaxCommentColumn
+}
+
+func (f *form) write(s string) {
+	if f.err != nil {
+		return
+	}
+
+	if f.newline != "\n" {
+		s = strings.ReplaceAll(s, "\n", f.newline)
+	}
+
+	_, f.err = io.WriteString(f.w, s)
+}
+
+func (f *form) indent(padding int) string {
+	if f.opts.UseTabs && f.opts.TabWidth > 0 {
+		return strings.Repeat("\t", padding/f.opts.TabWidth) + strings.Repeat(" ", padding%f.opts.TabWidth)
+	}
+
+	return strings.Repeat(" ", padding)
+}
+
+func (f *form) commentsPrint(g *ast.CommentGroup, padding int) {
+	for _, c := range g.List {
+		f.writeLine(padding, parseComment(c.Text).getString())
+	}
+}
+
+func (f *form) printErrors(nodes []*ast.Error) {
+	f.printEmptyLines(nodes[0].EmptyLines)
+	if nodes[0].Doc != nil {
+		f.commentsPrint(nodes[0].Doc, 0)
+	}
+
+	errors := make(ridlErrors, len(nodes))
+	for i, n := range nodes {
+		errors[i] = ridlError{
+			code:        n.Code,
+			name:        n.Name,
+			description: n.Description,
+			httpCode:    n.HTTPCode,
+			hasHTTPCode: n.HasHTTPCode,
+		}
+
+		if n.Comment != nil {
+			errors[i].inlineComment = parseComment(n.Comment.Text)
+		}
+	}
+
+	var codeLen, nameLen, descLen, httpLen int
+	if f.opts.AlignErrors {
+		codeLen, nameLen, descLen, httpLen = errors.getLenghts()
+	}
+
+	if f.opts.SortErrors {
+		if len(f.opts.ErrorSortKeys) != 0 {
+			sort.Stable(errorsByKeys{errors, f.opts.ErrorSortKeys})
+		} else {
+			sort.Stable(errors)
+		}
+	}
+
+	for _, err := range errors {
+		line := fmt.Sprintf("error %-*d %-*s \"%s\"%s",
+			codeLen,
+			err.code,
+			nameLen,
+			err.name,
+			err.description,
+			spaces(descLen-len(err.description)),
+		)
+
+		if err.hasHTTPCode {
+			line += fmt.Sprintf(" HTTP %-*d", httpLen, err.httpCode)
+		}
+
+		line = strings.TrimRight(line, " ")
+		if err.inlineComment != nil {
+			line = err.inlineComment.appendInlineComment(line)
+		}
+
+		f.writeLine(0, line)
+	}
+}
+
+// fieldWidths returns the width of the `name:` column for every field in a
+// struct body. Fields are aligned in runs that end at blank lines, comments
+// and anything that isn't a field.
+func fieldWidths(body []ast.Node) map[*ast.Field]int {
+	widths := map[*ast.Field]int{}
+
+	var run []*ast.Field
+	var width int
+	endRun := func() {
+		for _, field := range run {
+			widths[field] = width
+		}
+		run = nil
+		width = 0
+	}
+
+	for _, n := range body {
+		field, ok := n.(*ast.Field)
+		if !ok {
+			endRun()
+			continue
+		}
+
+		if field.EmptyLines > 0 || field.Doc != nil {
+			endRun()
+		}
+
+		run = append(run, field)
+		width = maxInt(width, len(field.Name)+1)
+
+		if hasComments(field.Tags) {
+			endRun()
+		}
+	}
+
+	endRun()
+
+	return widths
+}
+
+// tagWidths returns the width of the key column for every tag with a value
+// under a field. Tags are aligned in runs that end at blank lines, comments
+// and annotations.
+func tagWidths(tags []ast.Node) map[*ast.Tag]int {
+	widths := map[*ast.Tag]int{}
+
+	var run []*ast.Tag
+	var width int
+	endRun := func() {
+		for _, tag := range run {
+			widths[tag] = width
+		}
+		run = nil
+		width = 0
+	}
+
+	for _, n := range tags {
+		tag, ok := n.(*ast.Tag)
+		if !ok || tag.EmptyLines > 0 || tag.Doc != nil {
+			endRun()
+		}
+
+		if ok && tag.HasValue {
+			run = append(run, tag)
+			width = maxInt(width, utf8.RuneCountInString(tag.Key))
+		}
+	}
+
+	endRun()
+
+	return widths
+}
+
+// hasComments reports whether comment lines or blank lines are found among
+// nodes.
+func hasComments(nodes []ast.Node) bool {
+	for _, n := range nodes {
+		d, ok := n.(ast.Decorated)
+		if !ok || d.Decor().Doc != nil || d.Decor().EmptyLines > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+
+	return b
+}
+
+func spaces(n int) string {
+	if n <= 0 {
+		return ""
+	}
+
+	return strings.Repeat(" ", n)
+}
+
+func formatMethod(m *ast.Method) string {
+	var sb strings.Builder
+
+	sb.WriteString("- ")
+	if m.StreamInput {
+		sb.WriteString("stream ")
+	}
+	sb.WriteString(fmt.Sprintf("%s(%s)", m.Name, formatMethodArguments(m.Inputs)))
+
+	if m.HasOutputs {
+		sb.WriteString(" => ")
+		if m.StreamOutput {
+			sb.WriteString("stream ")
+		}
+		sb.WriteString(fmt.Sprintf("(%s)", formatMethodArguments(m.Outputs)))
+	}
+
+	return sb.String()
+}
+
+func formatMethodArguments(args []*ast.Argument) string {
+	parts := make([]string, len(args))
+	for i, a := range args {
+		parts[i] = fmt.Sprintf("%s: %s", a.Name, a.Type)
+	}
+
+	return strings.Join(parts, ", ")
+}
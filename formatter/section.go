@@ -1,20 +0,0 @@
-package formatter
-
-type section int
-
-const (
-	sectionUnknown section = iota
-	sectionEmpty
-	sectionWebRPC
-	sectionName
-	sectionVersion
-	sectionComment
-	sectionEnum
-	sectionStruct
-	sectionService
-	sectionField
-	sectionTag
-	sectionError
-	sectionImport
-	sectionAnnotation
-)
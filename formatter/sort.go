@@ -0,0 +1,140 @@
+package formatter
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/webrpc/ridlfmt/formatter/ast"
+)
+
+// sortFile reorders the parts of file that opts asks to sort and returns
+// warnings about sorts that may change the meaning of the schema.
+func sortFile(file *ast.File, opts Options) []Diagnostic {
+	var warnings []Diagnostic
+
+	for _, decl := range file.Decls {
+		switch n := decl.(type) {
+		case *ast.Import:
+			if opts.SortImports {
+				sortRuns(n.Body, func(n ast.Node) (string, bool) {
+					m, ok := n.(*ast.ImportMember)
+					if !ok {
+						return "", false
+					}
+
+					return m.Name, true
+				})
+			}
+		case *ast.Enum:
+			if opts.SortEnumValues {
+				if implicitEnumValues(n) {
+					warnings = append(warnings, Diagnostic{
+						Pos:      n.Pos(),
+						Severity: SeverityWarning,
+						Message:  fmt.Sprintf("enum %s has values without an explicit number, sorting them changes their numbers", n.Name),
+					})
+				}
+
+				sortRuns(n.Body, func(n ast.Node) (string, bool) {
+					v, ok := n.(*ast.EnumValue)
+					if !ok {
+						return "", false
+					}
+
+					return v.Name, true
+				})
+			}
+		case *ast.Struct:
+			if opts.SortTags {
+				for _, n := range n.Body {
+					if field, ok := n.(*ast.Field); ok {
+						sortRuns(field.Tags, func(n ast.Node) (string, bool) {
+							t, ok := n.(*ast.Tag)
+							if !ok {
+								return "", false
+							}
+
+							return t.Key, true
+						})
+					}
+				}
+			}
+		case *ast.Service:
+			if opts.SortAnnotations {
+				for _, n := range n.Body {
+					if l, ok := n.(*ast.AnnotationLine); ok {
+						sort.SliceStable(l.Annotations, func(i, j int) bool {
+							return l.Annotations[i].Name < l.Annotations[j].Name
+						})
+					}
+				}
+
+				// Annotations on consecutive lines above a method are
+				// sorted by their first annotation.
+				sortRuns(n.Body, func(n ast.Node) (string, bool) {
+					l, ok := n.(*ast.AnnotationLine)
+					if !ok || len(l.Annotations) == 0 {
+						return "", false
+					}
+
+					return l.Annotations[0].Name, true
+				})
+			}
+		}
+	}
+
+	return warnings
+}
+
+// sortRuns sorts every run of consecutive nodes that have a key. A blank
+// line, a comment line or a node without a key ends a run. Nodes keep their
+// inline comments, and what is above a run stays above it.
+func sortRuns(nodes []ast.Node, key func(n ast.Node) (string, bool)) {
+	for i := 0; i < len(nodes); {
+		if _, ok := key(nodes[i]); !ok {
+			i++
+			continue
+		}
+
+		end := i + 1
+		for end < len(nodes) {
+			if _, ok := key(nodes[end]); !ok || startsBlock(nodes[end]) {
+				break
+			}
+			end++
+		}
+
+		run := nodes[i:end]
+		first := *run[0].(ast.Decorated).Decor()
+		run[0].(ast.Decorated).Decor().EmptyLines = 0
+		run[0].(ast.Decorated).Decor().Doc = nil
+
+		sort.SliceStable(run, func(a, b int) bool {
+			ka, _ := key(run[a])
+			kb, _ := key(run[b])
+			return ka < kb
+		})
+
+		run[0].(ast.Decorated).Decor().EmptyLines = first.EmptyLines
+		run[0].(ast.Decorated).Decor().Doc = first.Doc
+
+		i = end
+	}
+}
+
+func startsBlock(n ast.Node) bool {
+	d := n.(ast.Decorated).Decor()
+	return d.EmptyLines > 0 || d.Doc != nil
+}
+
+// implicitEnumValues reports whether some values of e get their number from
+// their position.
+func implicitEnumValues(e *ast.Enum) bool {
+	for _, n := range e.Body {
+		if v, ok := n.(*ast.EnumValue); ok && v.Value == "" {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,113 @@
+package formatter
+
+import "github.com/webrpc/ridlfmt/formatter/ast"
+
+// spaceFile applies the blank line options to file, replacing the blank lines
+// found in the input where opts asks for a fixed number of them. prev is the
+// declaration printed before file, if any, and the last one of file is
+// returned.
+func spaceFile(file *ast.File, opts Options, prev ast.Node) ast.Node {
+	for _, decl := range file.Decls {
+		d, ok := decl.(ast.Decorated)
+		if ok {
+			switch {
+			case opts.CompactHeader && isDefinition(prev) && isDefinition(decl):
+				d.Decor().EmptyLines = 0
+			case opts.SeparateDecls && prev == nil:
+				d.Decor().EmptyLines = 0
+			case opts.SeparateDecls && !sameGroup(prev, decl):
+				d.Decor().EmptyLines = 1
+			}
+		}
+
+		switch n := decl.(type) {
+		case *ast.Import:
+			spaceBody(n.Body, opts)
+		case *ast.Enum:
+			spaceBody(n.Body, opts)
+		case *ast.Struct:
+			spaceBody(n.Body, opts)
+			if opts.SeparateTaggedFields {
+				separateTaggedFields(n.Body)
+			}
+		case *ast.Service:
+			spaceBody(n.Body, opts)
+		}
+
+		prev = decl
+	}
+
+	return prev
+}
+
+// sameGroup reports whether a and b are header lines or errors that are kept
+// together without blank lines forced between them.
+func sameGroup(a, b ast.Node) bool {
+	if isDefinition(a) && isDefinition(b) {
+		return true
+	}
+
+	_, aErr := a.(*ast.Error)
+	_, bErr := b.(*ast.Error)
+
+	return aErr && bErr
+}
+
+func spaceBody(body []ast.Node, opts Options) {
+	if !opts.TrimBlocks {
+		return
+	}
+
+	if len(body) != 0 {
+		setEmptyLines(body[0], 0)
+	}
+
+	// Floating comments and ridlfmt:off regions after a field are kept in
+	// its tags, but they aren't part of its block.
+	for _, n := range body {
+		if field, ok := n.(*ast.Field); ok && len(field.Tags) != 0 {
+			if tag, ok := field.Tags[0].(*ast.Tag); ok {
+				tag.EmptyLines = 0
+			}
+		}
+	}
+}
+
+// separateTaggedFields puts one blank line between a struct field with tags
+// and the fields next to it.
+func separateTaggedFields(body []ast.Node) {
+	for i := 1; i < len(body); i++ {
+		prev, ok := body[i-1].(*ast.Field)
+		if !ok {
+			continue
+		}
+
+		field, ok := body[i].(*ast.Field)
+		if ok && (hasTags(prev) || hasTags(field)) {
+			field.EmptyLines = 1
+		}
+	}
+}
+
+func hasTags(f *ast.Field) bool {
+	for _, n := range f.Tags {
+		if _, ok := n.(*ast.Tag); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func setEmptyLines(n ast.Node, emptyLines int) {
+	switch n := n.(type) {
+	case ast.Decorated:
+		n.Decor().EmptyLines = emptyLines
+	case *ast.CommentGroup:
+		n.EmptyLines = emptyLines
+	case *ast.Bad:
+		n.EmptyLines = emptyLines
+	case *ast.Verbatim:
+		n.EmptyLines = emptyLines
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const ignoreFileName = ".ridlfmtignore"
+
+type ignorePattern struct {
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// ignoreFile holds the patterns of one .ridlfmtignore, which are relative to
+// the directory it's in.
+type ignoreFile struct {
+	dir      string
+	patterns []ignorePattern
+}
+
+func loadIgnoreFile(fileName string) (*ignoreFile, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	ignore := &ignoreFile{dir: filepath.Dir(fileName)}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if p, ok := parseIgnorePattern(line); ok {
+			ignore.patterns = append(ignore.patterns, p)
+		}
+	}
+
+	return ignore, nil
+}
+
+// parseIgnorePattern converts a line of gitignore syntax into a regexp
+// matched against slash-separated paths relative to the ignore file.
+func parseIgnorePattern(line string) (ignorePattern, bool) {
+	var p ignorePattern
+
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return p, false
+	}
+
+	if strings.HasPrefix(line, "!") {
+		p.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\`) {
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+
+	if line == "" {
+		return p, false
+	}
+
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+
+	var re strings.Builder
+	if anchored {
+		re.WriteString("^")
+	} else {
+		re.WriteString("^(.*/)?")
+	}
+
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; c {
+		case '*':
+			switch {
+			case strings.HasPrefix(line[i:], "**/"):
+				re.WriteString("(.*/)?")
+				i += 2
+			case strings.HasPrefix(line[i:], "**"):
+				re.WriteString(".*")
+				i++
+			default:
+				re.WriteString("[^/]*")
+			}
+		case '?':
+			re.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(line[i:], ']')
+			if end == -1 {
+				re.WriteString(`\[`)
+				continue
+			}
+
+			class := line[i+1 : i+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			re.WriteString("[" + class + "]")
+			i += end
+		case '\\':
+			if i+1 < len(line) {
+				i++
+				re.WriteString(regexp.QuoteMeta(string(line[i])))
+			}
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	re.WriteString("$")
+
+	compiled, err := regexp.Compile(re.String())
+	if err != nil {
+		return p, false
+	}
+	p.re = compiled
+
+	return p, true
+}
+
+// match reports whether path is matched by the file's patterns and whether
+// it's ignored or explicitly included by the last matching pattern.
+func (f *ignoreFile) match(path string, isDir bool) (matched bool, ignored bool) {
+	rel, err := filepath.Rel(f.dir, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return false, false
+	}
+	rel = filepath.ToSlash(rel)
+
+	for _, p := range f.patterns {
+		if p.dirOnly && !isDir {
+			continue
+		}
+
+		if p.re.MatchString(rel) {
+			matched = true
+			ignored = !p.negate
+		}
+	}
+
+	return matched, ignored
+}
+
+// ignorer decides which paths are excluded by .ridlfmtignore files found
+// from the repository root down to the path.
+type ignorer struct {
+	files       *dirCache[[]*ignoreFile]
+	ignoredDirs map[string]bool
+}
+
+func newIgnorer() *ignorer {
+	return &ignorer{
+		files:       newDirCache(dirIgnoreFiles),
+		ignoredDirs: map[string]bool{},
+	}
+}
+
+// ignored reports whether path or one of its parent directories is ignored.
+func (ig *ignorer) ignored(path string, isDir bool) (bool, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return false, err
+	}
+
+	if isDir {
+		if ignored, ok := ig.ignoredDirs[path]; ok {
+			return ignored, nil
+		}
+	}
+
+	var ignored bool
+
+	if parent, ok := parentDir(path); ok {
+		ignored, err = ig.ignored(parent, true)
+		if err != nil {
+			return false, err
+		}
+
+		if !ignored {
+			files, err := ig.files.get(parent)
+			if err != nil {
+				return false, err
+			}
+
+			for _, f := range files {
+				if matched, ign := f.match(path, isDir); matched {
+					ignored = ign
+				}
+			}
+		}
+	}
+
+	if isDir {
+		ig.ignoredDirs[path] = ignored
+	}
+
+	return ignored, nil
+}
+
+// dirIgnoreFiles returns the ignore files that apply to entries of dir, the
+// ones of its parent directory followed by its own.
+func dirIgnoreFiles(dir string, parent []*ignoreFile) ([]*ignoreFile, error) {
+	fileName := filepath.Join(dir, ignoreFileName)
+	if !exists(fileName) {
+		return parent, nil
+	}
+
+	f, err := loadIgnoreFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(parent[:len(parent):len(parent)], f), nil
+}
@@ -1,19 +1,31 @@
 package main
 
 import (
-	"bufio"
 	"bytes"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 
+	"github.com/pmezard/go-difflib/difflib"
 	"github.com/webrpc/ridlfmt/formatter"
 )
 
+const stdinName = "<standard input>"
+
 func main() {
 	flagSet := flag.NewFlagSet("ridlfmt", flag.ExitOnError)
 	if err := runRidlfmt(flagSet, os.Args[1:]); err != nil {
+		var code exitCode
+		if errors.As(err, &code) {
+			os.Exit(int(code))
+		}
+
 		log.Fatalf("Error: %v", err)
 	}
 }
@@ -22,9 +34,24 @@ func runRidlfmt(flagSet *flag.FlagSet, args []string) error {
 	flag.Usage = usage
 
 	sortErrorsFlag := flagSet.Bool("s", false, "sort errors by code")
+	sortErrorsByFlag := flagSet.String("sort-errors", "", "sort errors by code, name or http, or by several comma-separated keys")
+	sortImportsFlag := flagSet.Bool("sort-imports", false, "sort import members by name")
+	sortTagsFlag := flagSet.Bool("sort-tags", false, "sort struct field tags by key")
+	sortAnnotationsFlag := flagSet.Bool("sort-annotations", false, "sort method annotations by name")
+	sortEnumValuesFlag := flagSet.Bool("sort-enum-values", false, "sort enum values by name (renumbers values without an explicit number)")
 	writeFlag := flagSet.Bool("w", false, "write output to input file (overwrites the file)")
+	listFlag := flagSet.Bool("l", false, "list files whose formatting differs from ridlfmt's")
+	diffFlag := flagSet.Bool("d", false, "display diffs instead of rewriting files")
+	checkFlag := flagSet.Bool("check", false, "list unformatted files and exit with 1 if there are any, 2 on errors")
+	verifyFlag := flagSet.Bool("verify", false, "fail if formatting the output again changes it")
+	noIgnoreFlag := flagSet.Bool("no-ignore", false, "format files named on the command line even if .ridlfmtignore excludes them")
+	jobsFlag := flagSet.Int("j", runtime.GOMAXPROCS(0), "number of files formatted in parallel")
 	helpFlag := flagSet.Bool("h", false, "show help")
 
+	var includeFlag, excludeFlag stringList
+	flagSet.Var(&includeFlag, "include", "glob of files to format in directories (default *.ridl, repeatable)")
+	flagSet.Var(&excludeFlag, "exclude", "glob of files or directories to skip in directories (repeatable)")
+
 	if err := flagSet.Parse(args); err != nil {
 		return fmt.Errorf("parse args: %w", err)
 	}
@@ -37,35 +64,177 @@ func runRidlfmt(flagSet *flag.FlagSet, args []string) error {
 	fileArgs := flagSet.Args()
 
 	if len(fileArgs) == 0 && !isInputFromPipe() {
-		fmt.Fprintln(os.Stderr, "error: no input files specified")
+		err := usageError("no input files specified")
 		flag.Usage()
-		os.Exit(1)
+		return err
 	}
 
-	if *writeFlag {
-		for _, fileName := range fileArgs {
-			err := formatAndWriteToFile(fileName, *sortErrorsFlag)
-			if err != nil {
-				log.Fatalf("Error processing file %s: %v", fileName, err)
-			}
+	setFlags := map[string]bool{}
+	flagSet.Visit(func(f *flag.Flag) {
+		setFlags[f.Name] = true
+	})
+
+	var errorSortKeys []formatter.ErrorSortKey
+	if setFlags["sort-errors"] {
+		var err error
+		errorSortKeys, err = formatter.ParseErrorSortKeys(*sortErrorsByFlag)
+		if err != nil {
+			return usageError("-sort-errors: %v", err)
 		}
-	} else {
-		if isInputFromPipe() {
-			err := formatAndPrintFromPipe(*sortErrorsFlag)
+	}
+
+	configs := newConfigResolver(func(opts *formatter.Options) {
+		if setFlags["s"] {
+			opts.SortErrors = *sortErrorsFlag
+			opts.ErrorSortKeys = nil
+		}
+		if setFlags["sort-errors"] {
+			opts.SortErrors = true
+			opts.ErrorSortKeys = errorSortKeys
+		}
+		if setFlags["sort-imports"] {
+			opts.SortImports = *sortImportsFlag
+		}
+		if setFlags["sort-tags"] {
+			opts.SortTags = *sortTagsFlag
+		}
+		if setFlags["sort-annotations"] {
+			opts.SortAnnotations = *sortAnnotationsFlag
+		}
+		if setFlags["sort-enum-values"] {
+			opts.SortEnumValues = *sortEnumValuesFlag
+		}
+	})
+
+	cfg := config{
+		write:  *writeFlag,
+		list:   *listFlag,
+		diff:   *diffFlag,
+		check:  *checkFlag,
+		verify: *verifyFlag,
+	}
+
+	if cfg.check && cfg.write {
+		return usageError("cannot use -check with -w")
+	}
+
+	if *jobsFlag < 1 {
+		return usageError("-j must be at least 1")
+	}
+
+	var failed, unformatted bool
+
+	if len(fileArgs) == 0 {
+		if cfg.write {
+			return usageError("cannot use -w with standard input")
+		}
+
+		var changed bool
+		inputBuffer, err := readPipe()
+		if err == nil {
+			cfg.options, err = configs.options(".")
+			cfg.options.Warn = warnTo(os.Stderr, stdinName)
+		}
+		if err == nil {
+			changed, err = processFile(os.Stdout, stdinName, inputBuffer.Bytes(), cfg)
+		}
+
+		if err != nil {
+			reportError(os.Stderr, stdinName, err)
+			failed = true
+		}
+		unformatted = unformatted || changed
+	}
+
+	w := walker{
+		include:  includeFlag,
+		exclude:  excludeFlag,
+		ignore:   newIgnorer(),
+		noIgnore: *noIgnoreFlag,
+	}
+	if len(w.include) == 0 {
+		w.include = defaultInclude
+	}
+
+	var files []inputFile
+	seen := map[string]bool{}
+	for _, arg := range fileArgs {
+		w.walk(arg, func(fileName string, err error) {
+			if seen[filepath.Clean(fileName)] {
+				return
+			}
+			seen[filepath.Clean(fileName)] = true
+
+			var opts formatter.Options
+			if err == nil {
+				opts, err = configs.options(filepath.Dir(fileName))
+			}
+
 			if err != nil {
-				log.Fatalf("Error processing input from pipe: %v", err)
+				reportError(os.Stderr, fileName, err)
+				failed = true
+				return
 			}
+
+			files = append(files, inputFile{name: fileName, options: opts})
+		})
+	}
+
+	filesFailed, filesChanged := formatFiles(files, cfg, *jobsFlag)
+	failed = failed || filesFailed
+	unformatted = unformatted || filesChanged
+
+	if failed {
+		return exitCode(2)
+	}
+
+	if cfg.check && unformatted {
+		return exitCode(1)
+	}
+
+	return nil
+}
+
+// exitCode is returned by runRidlfmt when the problems have already been
+// reported and the process should just exit with the given status.
+type exitCode int
+
+func (c exitCode) Error() string {
+	return fmt.Sprintf("exit status %d", int(c))
+}
+
+// usageError reports an invalid combination of flags and arguments. It exits
+// with 2 like other failures, so in -check mode it isn't mistaken for
+// unformatted files.
+func usageError(format string, args ...interface{}) error {
+	fmt.Fprintf(os.Stderr, "error: "+format+"\n", args...)
+	return exitCode(2)
+}
+
+// reportError prints err to w, one `file:line:col: message` line per
+// diagnostic when err carries formatter diagnostics.
+func reportError(w io.Writer, fileName string, err error) {
+	var diags formatter.Diagnostics
+	if !errors.As(err, &diags) {
+		fmt.Fprintf(w, "%s: %v\n", fileName, err)
+		return
+	}
+
+	for _, d := range diags {
+		if d.Pos.Line == 0 {
+			fmt.Fprintf(w, "%s: %v\n", fileName, d)
 		} else {
-			for _, fileName := range fileArgs {
-				err := formatAndPrintToStdout(fileName, *sortErrorsFlag)
-				if err != nil {
-					log.Fatalf("Error processing file %s: %v", fileName, err)
-				}
-			}
+			fmt.Fprintf(w, "%s:%v\n", fileName, d)
 		}
 	}
+}
 
-	return nil
+// warnTo returns a formatter.Options.Warn function printing the warnings of
+// fileName to w.
+func warnTo(w io.Writer, fileName string) func(formatter.Diagnostic) {
+	return func(d formatter.Diagnostic) {
+		fmt.Fprintf(w, "%s:%v\n", fileName, d)
+	}
 }
 
 func isInputFromPipe() bool {
@@ -73,68 +242,237 @@ func isInputFromPipe() bool {
 	return (stat.Mode() & os.ModeCharDevice) == 0
 }
 
-func formatAndWriteToFile(fileName string, sortErrorsFlag bool) error {
-	inputBytes, err := os.ReadFile(fileName)
-	if err != nil {
-		return fmt.Errorf("error opening input file %s: %w", fileName, err)
+type config struct {
+	options formatter.Options
+	write   bool
+	list    bool
+	diff    bool
+	check   bool
+	verify  bool
+}
+
+type inputFile struct {
+	name    string
+	options formatter.Options
+}
+
+type fileResult struct {
+	stdout  bytes.Buffer
+	stderr  bytes.Buffer
+	changed bool
+	failed  bool
+}
+
+// formatFiles processes files on up to jobs goroutines. Each file's output
+// and diagnostics are buffered and flushed in the order of files, so the
+// result doesn't depend on scheduling.
+func formatFiles(files []inputFile, cfg config, jobs int) (failed bool, unformatted bool) {
+	results := make([]fileResult, len(files))
+	done := make([]chan struct{}, len(files))
+	for i := range done {
+		done[i] = make(chan struct{})
 	}
 
-	output, err := formatter.Format(bytes.NewReader(inputBytes), sortErrorsFlag)
-	if err != nil {
-		return fmt.Errorf("error formatting input file %s: %w", fileName, err)
+	queue := make(chan int)
+	go func() {
+		for i := range files {
+			queue <- i
+		}
+		close(queue)
+	}()
+
+	for j := 0; j < jobs; j++ {
+		go func() {
+			for i := range queue {
+				r := &results[i]
+				fileCfg := cfg
+				fileCfg.options = files[i].options
+				fileCfg.options.Warn = warnTo(&r.stderr, files[i].name)
+
+				inputBytes, err := os.ReadFile(files[i].name)
+				if err == nil {
+					r.changed, err = processFile(&r.stdout, files[i].name, inputBytes, fileCfg)
+				}
+
+				if err != nil {
+					reportError(&r.stderr, files[i].name, err)
+					r.failed = true
+				}
+
+				close(done[i])
+			}
+		}()
 	}
 
-	err = os.WriteFile(fileName, []byte(output), 0644)
-	if err != nil {
-		return fmt.Errorf("error writing to output file %s: %w", fileName, err)
+	for i := range files {
+		<-done[i]
+
+		os.Stdout.Write(results[i].stdout.Bytes())
+		os.Stderr.Write(results[i].stderr.Bytes())
+
+		failed = failed || results[i].failed
+		unformatted = unformatted || results[i].changed
 	}
 
-	return nil
+	return failed, unformatted
 }
 
-func formatAndPrintToStdout(fileName string, sortErrorsFlag bool) error {
-	inputBytes, err := os.ReadFile(fileName)
+// processFile formats input read from fileName and, depending on cfg, lists
+// the file, prints a diff to w, writes the result back or prints it to w.
+// It reports whether the formatted output differs from input.
+func processFile(w io.Writer, fileName string, input []byte, cfg config) (bool, error) {
+	var output string
+	var err error
+	if cfg.verify {
+		var outputBytes []byte
+		outputBytes, err = formatter.Verify(input, cfg.options)
+		output = string(outputBytes)
+	} else {
+		output, err = formatter.FormatWithOptions(bytes.NewReader(input), cfg.options)
+	}
 	if err != nil {
-		return fmt.Errorf("error opening input file %s: %w", fileName, err)
+		return false, fmt.Errorf("error formatting input file %s: %w", fileName, err)
 	}
 
-	output, err := formatter.Format(bytes.NewReader(inputBytes), sortErrorsFlag)
-	if err != nil {
-		return fmt.Errorf("error formatting input file %s: %w", fileName, err)
+	changed := output != string(input)
+
+	if (cfg.list || cfg.check) && changed {
+		fmt.Fprintln(w, fileName)
 	}
 
-	fmt.Println(output)
+	if cfg.diff && changed {
+		diff, err := unifiedDiff(fileName, string(input), output)
+		if err != nil {
+			return changed, fmt.Errorf("error computing diff: %w", err)
+		}
 
-	return nil
+		fmt.Fprint(w, diff)
+	}
+
+	if cfg.write {
+		if !changed {
+			return false, nil
+		}
+
+		return changed, writeFile(fileName, output)
+	}
+
+	if !cfg.list && !cfg.diff && !cfg.check {
+		fmt.Fprint(w, output)
+	}
+
+	return changed, nil
 }
 
-func formatAndPrintFromPipe(sortErrorsFlag bool) error {
-	scanner := bufio.NewScanner(os.Stdin)
-	var inputBuffer bytes.Buffer
-	for scanner.Scan() {
-		inputBuffer.WriteString(scanner.Text())
-		inputBuffer.WriteByte('\n')
+// writeFile replaces the content of fileName with output. The output is
+// written to a temporary file next to the target, which then gets the
+// target's permissions and is renamed over it, so an interrupted run never
+// leaves a half-written schema behind. Symlinks are resolved first so the
+// link itself is kept.
+func writeFile(fileName string, output string) error {
+	target, err := filepath.EvalSymlinks(fileName)
+	if err != nil {
+		return fmt.Errorf("error resolving output file %s: %w", fileName, err)
 	}
 
-	if err := scanner.Err(); err != nil {
-		return fmt.Errorf("error reading from pipe: %w", err)
+	info, err := os.Stat(target)
+	if err != nil {
+		return fmt.Errorf("error reading output file %s: %w", fileName, err)
 	}
 
-	output, err := formatter.Format(&inputBuffer, sortErrorsFlag)
+	tmp, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target)+".ridlfmt-*")
 	if err != nil {
-		return fmt.Errorf("error formatting input from pipe: %w", err)
+		return fmt.Errorf("error writing to output file %s: %w", fileName, err)
 	}
+	defer os.Remove(tmp.Name())
 
-	fmt.Println(output)
+	_, err = tmp.WriteString(output)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), info.Mode().Perm())
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), target)
+	}
+	if err != nil {
+		return fmt.Errorf("error writing to output file %s: %w", fileName, err)
+	}
 
 	return nil
 }
 
+// unifiedDiff renders the changes between the original and the formatted
+// content of fileName in the same layout as `gofmt -d`.
+func unifiedDiff(fileName string, original string, formatted string) (string, error) {
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(original),
+		B:        splitLines(formatted),
+		FromFile: fileName + ".orig",
+		ToFile:   fileName,
+		Context:  3,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("diff %s.orig %s\n%s", fileName, fileName, diff), nil
+}
+
+// splitLines splits s for the diff. A last line without a newline gets the
+// marker diff prints for it, so it differs from the same line with one.
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		return lines[:len(lines)-1]
+	}
+
+	lines[len(lines)-1] += "\n\\ No newline at end of file\n"
+
+	return lines
+}
+
+func readPipe() (*bytes.Buffer, error) {
+	var inputBuffer bytes.Buffer
+	if _, err := inputBuffer.ReadFrom(os.Stdin); err != nil {
+		return nil, fmt.Errorf("error reading from pipe: %w", err)
+	}
+
+	return &inputBuffer, nil
+}
+
 func usage() {
 	fmt.Fprintf(os.Stderr, `usage: ridlfmt [flags] [path...]
 
+    -check
+          list unformatted files and exit with 1 if there are any, 2 on errors
+    -d    display diffs instead of rewriting files
+    -exclude glob
+          glob of files or directories to skip in directories (repeatable)
     -h    show help
+    -include glob
+          glob of files to format in directories (default *.ridl, repeatable)
+    -j n  number of files formatted in parallel (default GOMAXPROCS)
+    -l    list files whose formatting differs from ridlfmt's
+    -no-ignore
+          format files named on the command line even if .ridlfmtignore excludes them
     -s    sort errors by code
+    -sort-errors keys
+          sort errors by code, name or http, or by several comma-separated keys
+    -sort-annotations
+          sort method annotations by name
+    -sort-enum-values
+          sort enum values by name (renumbers values without an explicit number)
+    -sort-imports
+          sort import members by name
+    -sort-tags
+          sort struct field tags by key
+    -verify
+          fail if formatting the output again changes it
     -w    write result to (source) file instead of stdout 
 `)
 }
@@ -3,13 +3,17 @@ package main
 import (
 	"bytes"
 	"flag"
+	"fmt"
 	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
+	"github.com/webrpc/ridlfmt/formatter"
 )
 
 func TestFormatAndPrintFromPipe(t *testing.T) {
@@ -66,6 +70,398 @@ func TestFormatAndWriteToFile(t *testing.T) {
 	require.Equal(t, expectedOutput, string(outputBytes))
 }
 
+func TestWriteKeepsModeAndSymlinks(t *testing.T) {
+	dir := t.TempDir()
+
+	target := filepath.Join(dir, "schema.ridl")
+	require.NoError(t, os.WriteFile(target, []byte(testInput), 0600))
+
+	link := filepath.Join(dir, "link.ridl")
+	require.NoError(t, os.Symlink(target, link))
+
+	flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+	require.NoError(t, runRidlfmt(flagSet, []string{"-w", "-s", link}))
+
+	linkInfo, err := os.Lstat(link)
+	require.NoError(t, err)
+	require.True(t, linkInfo.Mode()&os.ModeSymlink != 0)
+
+	info, err := os.Stat(target)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	outputBytes, err := os.ReadFile(target)
+	require.NoError(t, err)
+	require.Equal(t, expectedOutput, string(outputBytes))
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	// Formatting an already formatted file must not touch it.
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	require.NoError(t, os.Chtimes(target, modTime, modTime))
+
+	flagSet = flag.NewFlagSet("test", flag.ContinueOnError)
+	require.NoError(t, runRidlfmt(flagSet, []string{"-w", "-s", target}))
+
+	info, err = os.Stat(target)
+	require.NoError(t, err)
+	require.Equal(t, modTime, info.ModTime())
+}
+
+func TestFormatContinuesAfterError(t *testing.T) {
+	dir := t.TempDir()
+
+	badFile := dir + "/bad.ridl"
+	badInput := "struct User\n  - id uint64\n"
+	require.NoError(t, os.WriteFile(badFile, []byte(badInput), 0644))
+
+	goodFile := dir + "/good.ridl"
+	require.NoError(t, os.WriteFile(goodFile, []byte(testInput), 0644))
+
+	flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+
+	args := []string{"-w", "-s", badFile, goodFile}
+	err := runRidlfmt(flagSet, args)
+	require.Equal(t, exitCode(2), err)
+
+	outputBytes, err := os.ReadFile(badFile)
+	require.NoError(t, err)
+	require.Equal(t, badInput, string(outputBytes))
+
+	outputBytes, err = os.ReadFile(goodFile)
+	require.NoError(t, err)
+	require.Equal(t, expectedOutput, string(outputBytes))
+}
+
+func TestListFlag(t *testing.T) {
+	dir := t.TempDir()
+
+	formattedFile := dir + "/formatted.ridl"
+	require.NoError(t, os.WriteFile(formattedFile, []byte(expectedOutput), 0644))
+
+	unformattedFile := dir + "/unformatted.ridl"
+	require.NoError(t, os.WriteFile(unformattedFile, []byte(testInput), 0644))
+
+	flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+
+	args := []string{"-l", "-s", formattedFile, unformattedFile}
+	out := captureStdout(t, func() {
+		require.NoError(t, runRidlfmt(flagSet, args))
+	})
+
+	require.Equal(t, unformattedFile+"\n", out)
+
+	outputBytes, err := os.ReadFile(unformattedFile)
+	require.NoError(t, err)
+	require.Equal(t, testInput, string(outputBytes))
+}
+
+func TestDiffFlag(t *testing.T) {
+	fileName := t.TempDir() + "/schema.ridl"
+	input := "webrpc   = v1\n\nstruct User\n  - id:   uint64\n"
+	require.NoError(t, os.WriteFile(fileName, []byte(input), 0644))
+
+	flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+
+	out := captureStdout(t, func() {
+		require.NoError(t, runRidlfmt(flagSet, []string{"-d", fileName}))
+	})
+
+	expected := "diff " + fileName + ".orig " + fileName + `
+--- ` + fileName + `.orig
++++ ` + fileName + `
+@@ -1,4 +1,4 @@
+-webrpc   = v1
++webrpc = v1
+ 
+ struct User
+-  - id:   uint64
++  - id: uint64
+`
+	require.Equal(t, expected, out)
+
+	require.NoError(t, os.WriteFile(fileName, []byte("webrpc = v1\n\nstruct User\n  - id: uint64"), 0644))
+
+	flagSet = flag.NewFlagSet("test", flag.ContinueOnError)
+	out = captureStdout(t, func() {
+		require.NoError(t, runRidlfmt(flagSet, []string{"-d", fileName}))
+	})
+
+	expected = "diff " + fileName + ".orig " + fileName + `
+--- ` + fileName + `.orig
++++ ` + fileName + `
+@@ -1,4 +1,4 @@
+ webrpc = v1
+ 
+ struct User
+-  - id: uint64
+\ No newline at end of file
++  - id: uint64
+`
+	require.Equal(t, expected, out)
+}
+
+func TestCheckFlag(t *testing.T) {
+	dir := t.TempDir()
+
+	formattedFile := dir + "/formatted.ridl"
+	require.NoError(t, os.WriteFile(formattedFile, []byte(expectedOutput), 0644))
+
+	unformattedFile := dir + "/unformatted.ridl"
+	require.NoError(t, os.WriteFile(unformattedFile, []byte(testInput), 0644))
+
+	badFile := dir + "/bad.ridl"
+	require.NoError(t, os.WriteFile(badFile, []byte("struct User\n  - id uint64\n"), 0644))
+
+	tests := []struct {
+		flags    []string
+		files    []string
+		expected error
+	}{
+		{nil, []string{formattedFile}, nil},
+		{nil, []string{formattedFile, unformattedFile}, exitCode(1)},
+		{nil, []string{unformattedFile, badFile}, exitCode(2)},
+
+		// Usage errors must not look like unformatted files.
+		{[]string{"-w"}, []string{formattedFile}, exitCode(2)},
+		{[]string{"-j", "0"}, []string{formattedFile}, exitCode(2)},
+		{[]string{"-sort-errors=status"}, []string{formattedFile}, exitCode(2)},
+	}
+
+	for _, tt := range tests {
+		flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+		args := append(append([]string{"--check", "-s"}, tt.flags...), tt.files...)
+
+		captureStdout(t, func() {
+			require.Equal(t, tt.expected, runRidlfmt(flagSet, args))
+		})
+	}
+
+	// Without files, a terminal on stdin is a usage error too.
+	stdin := os.Stdin
+	defer func() { os.Stdin = stdin }()
+	devNull, err := os.Open(os.DevNull)
+	require.NoError(t, err)
+	defer devNull.Close()
+	os.Stdin = devNull
+
+	flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+	require.Equal(t, exitCode(2), runRidlfmt(flagSet, []string{"--check"}))
+
+	outputBytes, err := os.ReadFile(unformattedFile)
+	require.NoError(t, err)
+	require.Equal(t, testInput, string(outputBytes))
+}
+
+func TestWalkDirectories(t *testing.T) {
+	dir := t.TempDir()
+
+	writeFiles(t, dir, map[string]string{
+		"a.ridl":              testInput,
+		"notes.txt":           testInput,
+		"sub/b.ridl":          testInput,
+		"gen/c.ridl":          testInput,
+		".hidden/d.ridl":      testInput,
+		"vendor/e.ridl":       testInput,
+		"node_modules/f.ridl": testInput,
+	})
+
+	flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+
+	args := []string{"-l", "-exclude", "gen", dir}
+	out := captureStdout(t, func() {
+		require.NoError(t, runRidlfmt(flagSet, args))
+	})
+
+	expected := filepath.Join(dir, "a.ridl") + "\n" + filepath.Join(dir, "sub/b.ridl") + "\n"
+	require.Equal(t, expected, out)
+}
+
+func TestParallelOutputOrder(t *testing.T) {
+	dir := t.TempDir()
+
+	var files []string
+	var expected string
+	for i := 0; i < 50; i++ {
+		fileName := filepath.Join(dir, fmt.Sprintf("schema%02d.ridl", i))
+		require.NoError(t, os.WriteFile(fileName, []byte(testInput), 0644))
+
+		files = append(files, fileName)
+		expected += fileName + "\n"
+	}
+
+	flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+
+	args := append([]string{"-l", "-j", "8"}, files...)
+	out := captureStdout(t, func() {
+		require.NoError(t, runRidlfmt(flagSet, args))
+	})
+
+	require.Equal(t, expected, out)
+}
+
+func TestConfigFiles(t *testing.T) {
+	dir := t.TempDir()
+	input := "struct User\n  - id: uint64\n    + json = id\n\nerror 2 B \"b\" HTTP 400\nerror 1 A \"a\" HTTP 400\n"
+
+	files := map[string]string{
+		".git/HEAD":           "",
+		".ridlfmt.yaml":       "sort-errors: true\ntag-indent: 6\n",
+		"sub/.ridlfmt.toml":   "tag-indent = 8\n",
+		"a.ridl":              input,
+		"sub/b.ridl":          input,
+		"sub/nested/c.ridl":   input,
+		"other/.ridlfmt.yaml": "sort-errors: false\n",
+		"other/d.ridl":        input,
+	}
+	writeFiles(t, dir, files)
+
+	flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+	require.NoError(t, runRidlfmt(flagSet, []string{"-w", dir}))
+
+	sorted := "error 1 A \"a\" HTTP 400\nerror 2 B \"b\" HTTP 400\n"
+	unsorted := "error 2 B \"b\" HTTP 400\nerror 1 A \"a\" HTTP 400\n"
+
+	expected := map[string]string{
+		"a.ridl":            "struct User\n  - id: uint64\n      + json = id\n\n" + sorted,
+		"sub/b.ridl":        "struct User\n  - id: uint64\n        + json = id\n\n" + sorted,
+		"sub/nested/c.ridl": "struct User\n  - id: uint64\n        + json = id\n\n" + sorted,
+		"other/d.ridl":      "struct User\n  - id: uint64\n      + json = id\n\n" + unsorted,
+	}
+	for name, content := range expected {
+		outputBytes, err := os.ReadFile(filepath.Join(dir, name))
+		require.NoError(t, err)
+		require.Equal(t, content, string(outputBytes), name)
+	}
+
+	// Flags given on the command line win over config files.
+	flagSet = flag.NewFlagSet("test", flag.ContinueOnError)
+	out := captureStdout(t, func() {
+		require.NoError(t, runRidlfmt(flagSet, []string{"-s", filepath.Join(dir, "other/d.ridl")}))
+	})
+	require.Equal(t, "struct User\n  - id: uint64\n      + json = id\n\n"+sorted, out)
+
+	// Invalid values are reported instead of crashing a worker.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "other/.ridlfmt.yaml"), []byte("field-indent: -2\n"), 0644))
+	flagSet = flag.NewFlagSet("test", flag.ContinueOnError)
+	require.Equal(t, exitCode(2), runRidlfmt(flagSet, []string{"-l", filepath.Join(dir, "other/d.ridl")}))
+}
+
+func TestSortErrorsFlag(t *testing.T) {
+	dir := t.TempDir()
+	input := "error 3 C \"c\" HTTP 500\nerror 2 A \"a\" HTTP 400\nerror 1 B \"b\" HTTP 500\n"
+
+	fileName := filepath.Join(dir, "a.ridl")
+	require.NoError(t, os.WriteFile(fileName, []byte(input), 0644))
+
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"-sort-errors=name"}, "error 2 A \"a\" HTTP 400\nerror 1 B \"b\" HTTP 500\nerror 3 C \"c\" HTTP 500\n"},
+		{[]string{"-sort-errors=http,code"}, "error 2 A \"a\" HTTP 400\nerror 1 B \"b\" HTTP 500\nerror 3 C \"c\" HTTP 500\n"},
+		{[]string{"-sort-errors", "code"}, "error 1 B \"b\" HTTP 500\nerror 2 A \"a\" HTTP 400\nerror 3 C \"c\" HTTP 500\n"},
+	}
+
+	for _, tt := range tests {
+		flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+		out := captureStdout(t, func() {
+			require.NoError(t, runRidlfmt(flagSet, append(tt.args, fileName)))
+		})
+		require.Equal(t, tt.expected, out, tt.args)
+	}
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".ridlfmt.toml"), []byte("sort-errors = \"http, name\"\n"), 0644))
+	flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+	out := captureStdout(t, func() {
+		require.NoError(t, runRidlfmt(flagSet, []string{fileName}))
+	})
+	require.Equal(t, "error 2 A \"a\" HTTP 400\nerror 1 B \"b\" HTTP 500\nerror 3 C \"c\" HTTP 500\n", out)
+
+	flagSet = flag.NewFlagSet("test", flag.ContinueOnError)
+	require.Equal(t, exitCode(2), runRidlfmt(flagSet, []string{"-sort-errors=status", fileName}))
+}
+
+func TestIgnoreFile(t *testing.T) {
+	dir := t.TempDir()
+
+	files := map[string]string{
+		".git/HEAD":              "",
+		".ridlfmtignore":         "# generated\ngen/\n*.gen.ridl\n!keep.gen.ridl\n/third_party/**/*.ridl\n",
+		"sub/.ridlfmtignore":     "d.ridl\n",
+		"a.ridl":                 testInput,
+		"gen/b.ridl":             testInput,
+		"x.gen.ridl":             testInput,
+		"keep.gen.ridl":          testInput,
+		"third_party/foo/c.ridl": testInput,
+		"sub/d.ridl":             testInput,
+		"sub/e.ridl":             testInput,
+	}
+	writeFiles(t, dir, files)
+
+	tests := []struct {
+		args     []string
+		expected []string
+	}{
+		{[]string{dir}, []string{"a.ridl", "keep.gen.ridl", "sub/e.ridl"}},
+		{[]string{filepath.Join(dir, "gen/b.ridl"), filepath.Join(dir, "sub/d.ridl")}, nil},
+		{[]string{"-no-ignore", filepath.Join(dir, "gen/b.ridl")}, []string{"gen/b.ridl"}},
+	}
+
+	for _, tt := range tests {
+		flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+
+		out := captureStdout(t, func() {
+			require.NoError(t, runRidlfmt(flagSet, append([]string{"-l"}, tt.args...)))
+		})
+
+		var expected string
+		for _, name := range tt.expected {
+			expected += filepath.Join(dir, name) + "\n"
+		}
+		require.Equal(t, expected, out)
+	}
+}
+
+// writeFiles creates files, keyed by slash-separated paths relative to dir,
+// along with their parent directories.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	for name, content := range files {
+		fileName := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(fileName), 0755))
+		require.NoError(t, os.WriteFile(fileName, []byte(content), 0644))
+	}
+}
+
+func captureStdout(t *testing.T, fn func()) string {
+	rOut, wOut, err := os.Pipe()
+	require.NoError(t, err)
+
+	oldStdout := os.Stdout
+	defer func() {
+		os.Stdout = oldStdout
+	}()
+	os.Stdout = wOut
+
+	var out bytes.Buffer
+	copied := make(chan error)
+	go func() {
+		_, err := io.Copy(&out, rOut)
+		copied <- err
+	}()
+
+	fn()
+	wOut.Close()
+
+	require.NoError(t, <-copied)
+
+	return out.String()
+}
+
 func testHelpFlag(t *testing.T) {
 	cmd := exec.Command(os.Args[0], "-h")
 
@@ -261,3 +657,126 @@ service ExampleService # oof
   - stream SendAndRecv(req: string) => stream (resp: string)
   - streamSendAndRecv(req: string) => stream (resp: string)
 `
+
+func TestFormatLongLineFromPipe(t *testing.T) {
+	r, w, _ := os.Pipe()
+	oldStdin := os.Stdin
+	defer func() {
+		os.Stdin = oldStdin
+	}()
+	os.Stdin = r
+
+	comment := "# " + strings.Repeat("x", 100*1024)
+	go func() {
+		w.Write([]byte("webrpc   = v1 " + comment + "\n"))
+		w.Close()
+	}()
+
+	flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+	out := captureStdout(t, func() {
+		require.NoError(t, runRidlfmt(flagSet, nil))
+	})
+
+	require.Equal(t, "webrpc = v1 "+comment+"\n", out)
+}
+
+func TestGoldenFilesIdempotent(t *testing.T) {
+	opts := formatter.DefaultOptions()
+	opts.SortErrors = true
+
+	output, err := formatter.Verify([]byte(testInput), opts)
+	require.NoError(t, err)
+	require.Equal(t, expectedOutput, string(output))
+
+	output, err = formatter.Verify([]byte(expectedOutput), opts)
+	require.NoError(t, err)
+	require.Equal(t, expectedOutput, string(output))
+
+	examples, err := filepath.Glob("_examples/*.ridl")
+	require.NoError(t, err)
+	require.NotEmpty(t, examples)
+
+	goldens := map[string]string{
+		"testInput":      testInput,
+		"expectedOutput": expectedOutput,
+	}
+	for _, fileName := range examples {
+		input, err := os.ReadFile(fileName)
+		require.NoError(t, err)
+
+		goldens[fileName] = string(input)
+	}
+
+	optionSets := map[string]func(opts *formatter.Options){
+		"default": func(opts *formatter.Options) {},
+		"max-width": func(opts *formatter.Options) {
+			opts.MaxWidth = 30
+		},
+		"align": func(opts *formatter.Options) {
+			opts.AlignErrors = false
+			opts.AlignFields = true
+			opts.AlignFieldComments = true
+			opts.AlignTags = true
+			opts.AlignComments = true
+			opts.MaxCommentColumn = 40
+		},
+		"sort": func(opts *formatter.Options) {
+			opts.SortErrors = true
+			opts.ErrorSortKeys = []formatter.ErrorSortKey{formatter.ErrorSortHTTP, formatter.ErrorSortName}
+			opts.SortImports = true
+			opts.SortTags = true
+			opts.SortAnnotations = true
+			opts.SortEnumValues = true
+		},
+		"blank-lines": func(opts *formatter.Options) {
+			opts.SeparateDecls = true
+			opts.SeparateTaggedFields = true
+			opts.CompactHeader = true
+			opts.TrimBlocks = true
+		},
+		"tabs": func(opts *formatter.Options) {
+			opts.UseTabs = true
+			opts.FieldIndent = 4
+			opts.TagIndent = 6
+			opts.TabWidth = 4
+		},
+		"crlf": func(opts *formatter.Options) {
+			opts.LineEnding = formatter.LineEndingCRLF
+		},
+		"all": func(opts *formatter.Options) {
+			opts.MaxWidth = 30
+			opts.AlignFields = true
+			opts.AlignTags = true
+			opts.AlignComments = true
+			opts.SortTags = true
+			opts.SortAnnotations = true
+			opts.SeparateDecls = true
+			opts.SeparateTaggedFields = true
+			opts.UseTabs = true
+		},
+	}
+
+	for setName, set := range optionSets {
+		opts := formatter.DefaultOptions()
+		set(&opts)
+
+		for name, input := range goldens {
+			for _, src := range []string{input, strings.ReplaceAll(input, "\n", "\r\n")} {
+				_, err := formatter.Verify([]byte(src), opts)
+				require.NoError(t, err, "%s with %s options", name, setName)
+			}
+		}
+	}
+}
+
+func TestVerifyFlag(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "schema.ridl")
+	require.NoError(t, os.WriteFile(fileName, []byte(testInput), 0644))
+
+	flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+	require.NoError(t, runRidlfmt(flagSet, []string{"--verify", "-w", "-s", fileName}))
+
+	outputBytes, err := os.ReadFile(fileName)
+	require.NoError(t, err)
+	require.Equal(t, expectedOutput, string(outputBytes))
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+var defaultInclude = []string{"*.ridl"}
+
+// skippedDirs are never descended into when walking a directory argument.
+var skippedDirs = map[string]bool{
+	"vendor":       true,
+	"node_modules": true,
+}
+
+// stringList is a flag.Value collecting every occurrence of a repeated flag.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+type walker struct {
+	include []string
+	exclude []string
+
+	// ignore skips paths listed in .ridlfmtignore files. Paths given on the
+	// command line are only checked when noIgnore is false.
+	ignore   *ignorer
+	noIgnore bool
+}
+
+// walk calls fn for root if it's a file, or for every included file below it
+// if it's a directory. Hidden directories, vendor and node_modules are
+// skipped, as is anything matching an exclude glob or a .ridlfmtignore file.
+func (w walker) walk(root string, fn func(fileName string, err error)) {
+	info, err := os.Stat(root)
+	if err != nil {
+		fn(root, err)
+		return
+	}
+
+	if !w.noIgnore {
+		ignored, err := w.ignore.ignored(root, info.IsDir())
+		if err != nil || ignored {
+			if err != nil {
+				fn(root, err)
+			}
+			return
+		}
+	}
+
+	if !info.IsDir() {
+		fn(root, nil)
+		return
+	}
+
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			fn(p, err)
+			return nil
+		}
+
+		if p == root {
+			return nil
+		}
+
+		rel, _ := filepath.Rel(root, p)
+
+		ignored, err := w.ignore.ignored(p, d.IsDir())
+		if err != nil {
+			fn(p, err)
+			return nil
+		}
+
+		if d.IsDir() {
+			if ignored || strings.HasPrefix(d.Name(), ".") || skippedDirs[d.Name()] || w.excluded(rel, d.Name()) {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if !ignored && matchAny(w.include, rel, d.Name()) && !w.excluded(rel, d.Name()) {
+			fn(p, nil)
+		}
+
+		return nil
+	})
+	if err != nil {
+		fn(root, err)
+	}
+}
+
+func (w walker) excluded(rel string, name string) bool {
+	return matchAny(w.exclude, rel, name)
+}
+
+// matchAny reports whether one of the globs matches the file name or its
+// slash-separated path relative to the walked directory.
+func matchAny(globs []string, rel string, name string) bool {
+	rel = filepath.ToSlash(rel)
+	for _, g := range globs {
+		if ok, _ := filepath.Match(g, name); ok {
+			return true
+		}
+
+		if ok, _ := path.Match(g, rel); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// dirCache holds a value for every directory, built on the value of its
+// parent directory from the repository root (the closest directory containing
+// .git) down. The root starts from the zero value.
+type dirCache[T any] struct {
+	values map[string]T
+	load   func(dir string, parent T) (T, error)
+}
+
+func newDirCache[T any](load func(dir string, parent T) (T, error)) *dirCache[T] {
+	return &dirCache[T]{
+		values: map[string]T{},
+		load:   load,
+	}
+}
+
+func (c *dirCache[T]) get(dir string) (T, error) {
+	if v, ok := c.values[dir]; ok {
+		return v, nil
+	}
+
+	var v T
+	if parent, ok := parentDir(dir); ok {
+		var err error
+		v, err = c.get(parent)
+		if err != nil {
+			return v, err
+		}
+	}
+
+	v, err := c.load(dir, v)
+	if err != nil {
+		return v, err
+	}
+
+	c.values[dir] = v
+
+	return v, nil
+}
+
+// parentDir returns the parent of path, unless path is the repository root
+// or the root of the file system.
+func parentDir(path string) (string, bool) {
+	parent := filepath.Dir(path)
+	return parent, parent != path && !exists(filepath.Join(path, ".git"))
+}